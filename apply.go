@@ -0,0 +1,247 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// Apply applies patch to the json encoded document doc as specified in RFC 6902
+// and returns the json encoded result.
+//
+// Numbers keep their exact value and representation, test operations compare
+// them by value.
+//
+// The operations are applied in order. The first operation that fails aborts
+// the whole patch and its error is returned. It is an *InvalidDocumentError if
+// doc cannot be decoded, otherwise a *PathNotFoundError, *TestFailedError or
+// *InvalidOperationError identifying the operation.
+func Apply(doc []byte, patch []JSONPatchOperation) ([]byte, error) {
+	d, err := decodeDocument(doc, "original", true)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range patch {
+		d, err = applyOperation(d, op)
+		if err != nil {
+			return nil, operationError(i, op, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+func applyOperation(doc interface{}, op JSONPatchOperation) (interface{}, error) {
+	path, err := pointer.Parse(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Operation {
+	case "add":
+		value, err := normaliseValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case "replace":
+		value, err := normaliseValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return replaceValue(doc, path, value)
+	case "move":
+		from, err := pointer.Parse(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if path.HasPrefix(from) {
+			if len(from) == len(path) {
+				return doc, nil
+			}
+			return nil, fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		doc, value, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "copy":
+		from, err := pointer.Parse(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := from.Eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		value, err = normaliseValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "test":
+		value, err := normaliseValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := path.Eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !(&options{}).equal(actual, value) {
+			return nil, &TestFailedError{Path: op.Path, Expected: value, Actual: actual}
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Operation)
+}
+
+// arrayIndex parses token as an index into node. When appending is true the
+// index may point one past the end, either explicitly or through "-".
+func arrayIndex(path pointer.Pointer, depth int, node []interface{}, appending bool) (int, error) {
+	token := path[depth]
+	if appending && token == "-" {
+		return len(node), nil
+	}
+	n := len(node)
+	if appending {
+		n++
+	}
+	i, err := pointer.Index(token, n)
+	if err != nil {
+		return 0, &pointer.Error{Pointer: path.String(), Token: depth, Err: err}
+	}
+	return i, nil
+}
+
+func pointerError(path pointer.Pointer, depth int, err error) error {
+	return &pointer.Error{Pointer: path.String(), Token: depth, Err: err}
+}
+
+// updateParent walks down path and calls update with the container holding the
+// value path refers to. The container returned by update replaces the original
+// in its own parent, which allows arrays to change length.
+func updateParent(doc interface{}, path pointer.Pointer, depth int, update func(parent interface{}) (interface{}, error)) (interface{}, error) {
+	if depth == len(path)-1 {
+		return update(doc)
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[depth]]
+		if !ok {
+			return nil, pointerError(path, depth, pointer.ErrKeyNotFound)
+		}
+		child, err := updateParent(child, path, depth+1, update)
+		if err != nil {
+			return nil, err
+		}
+		node[path[depth]] = child
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(path, depth, node, false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateParent(node[i], path, depth+1, update)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, pointerError(path, depth, pointer.ErrNotContainer)
+}
+
+func addValue(doc interface{}, path pointer.Pointer, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	last := len(path) - 1
+	return updateParent(doc, path, 0, func(parent interface{}) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[path[last]] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(path, last, node, true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, pointerError(path, last, pointer.ErrNotContainer)
+	})
+}
+
+func removeValue(doc interface{}, path pointer.Pointer) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	last := len(path) - 1
+	var removed interface{}
+	doc, err := updateParent(doc, path, 0, func(parent interface{}) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			v, ok := node[path[last]]
+			if !ok {
+				return nil, pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			removed = v
+			delete(node, path[last])
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(path, last, node, false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i:i], node[i+1:]...), nil
+		}
+		return nil, pointerError(path, last, pointer.ErrNotContainer)
+	})
+	return doc, removed, err
+}
+
+func replaceValue(doc interface{}, path pointer.Pointer, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	last := len(path) - 1
+	return updateParent(doc, path, 0, func(parent interface{}) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[path[last]]; !ok {
+				return nil, pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			node[path[last]] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(path, last, node, false)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+		return nil, pointerError(path, last, pointer.ErrNotContainer)
+	})
+}
+
+// normaliseValue returns a deep copy of v in the representation decoded
+// documents have, numbers being json.Number, so values built in Go compare
+// equal to decoded ones.
+func normaliseValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var n interface{}
+	err = unmarshalUseNumber(b, &n)
+	return n, err
+}
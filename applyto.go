@@ -0,0 +1,408 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// ApplyTo applies patch to the Go value target points to, which is modified in
+// place without being encoded as json.
+//
+// Paths are resolved the way json.Marshal encodes target: struct fields by
+// the names in their json tags, maps by their keys and slices and arrays by
+// index. Values are converted into the type of their destination the way
+// json.Unmarshal would decode them, a *TypeMismatchError wrapped in an
+// *InvalidOperationError is returned if that is not possible. Struct fields
+// cannot be added or removed, adding sets the field and removing resets it
+// to its zero value. Elements of arrays of fixed length may be removed and
+// added, or moved, as long as the array has its length again once the
+// operations on its elements are done.
+//
+// Errors are reported like by Apply. The operations before the failing one
+// have already been applied to target.
+func ApplyTo(target interface{}, patch []JSONPatchOperation) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("ApplyTo needs a non-nil pointer, not %T", target)
+	}
+	t := &goTarget{root: rv.Elem(), arrays: map[string]*arrayEdit{}}
+	for i, op := range patch {
+		// arrays whose elements are not all op changes get their length
+		// checked and are copied back
+		if err := t.flushArrays(func(e *arrayEdit) bool { return !changesElements(op, e.path) }); err != nil {
+			return err
+		}
+		if err := t.apply(op); err != nil {
+			// keep what the operations before did to arrays, as far as
+			// their length allows
+			t.flushArrays(nil)
+			return operationError(i, op, err)
+		}
+		for _, e := range t.arrays {
+			e.index, e.op = i, op
+		}
+	}
+	return t.flushArrays(nil)
+}
+
+// goTarget is a Go value patches are applied to. Arrays of fixed length are
+// edited as slices while consecutive operations change their elements, as
+// removing and adding an element changes the length in between, and copied
+// back once an operation elsewhere follows.
+type goTarget struct {
+	root     reflect.Value
+	arrays   map[string]*arrayEdit
+	flushing bool
+}
+
+// arrayEdit is the slice an array of fixed length is edited as.
+type arrayEdit struct {
+	path  pointer.Pointer
+	slice reflect.Value
+	// index is the last operation using the slice, blamed if its length
+	// is wrong
+	index int
+	op    JSONPatchOperation
+}
+
+// changesElements reports whether op only refers to values inside the array
+// at path.
+func changesElements(op JSONPatchOperation, path pointer.Pointer) bool {
+	paths := []string{op.Path}
+	if op.Operation == "move" || op.Operation == "copy" {
+		paths = append(paths, op.From)
+	}
+	for _, s := range paths {
+		p, err := pointer.Parse(s)
+		if err != nil || len(p) <= len(path) || !p.HasPrefix(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// flushArrays copies the slices of the arrays selected by filter, or of all
+// arrays if it is nil, back into them, the innermost arrays first. The first
+// array whose slice has the wrong length is reported.
+func (t *goTarget) flushArrays(filter func(*arrayEdit) bool) error {
+	var firstErr error
+	var edits []*arrayEdit
+	for _, e := range t.arrays {
+		if filter == nil || filter(e) {
+			edits = append(edits, e)
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return len(edits[i].path) > len(edits[j].path) })
+	for _, e := range edits {
+		t.flushing = true
+		// the token is not looked at, the update only needs the array
+		err := t.update(t.root, append(e.path[:len(e.path):len(e.path)], "-"), 0, func(c reflect.Value) error {
+			if c.Len() != e.slice.Len() {
+				return fmt.Errorf("%q is an array of fixed length %d, not %d", e.path.String(), c.Len(), e.slice.Len())
+			}
+			reflect.Copy(c, e.slice)
+			return nil
+		})
+		t.flushing = false
+		delete(t.arrays, e.path.String())
+		if err != nil && firstErr == nil {
+			firstErr = operationError(e.index, e.op, err)
+		}
+	}
+	return firstErr
+}
+
+// arraySlice returns the slice the array c at path is edited as.
+func (t *goTarget) arraySlice(c reflect.Value, path pointer.Pointer) reflect.Value {
+	key := path.String()
+	if e, ok := t.arrays[key]; ok {
+		return e.slice
+	}
+	s := reflect.New(reflect.SliceOf(c.Type().Elem())).Elem()
+	s.Set(reflect.MakeSlice(s.Type(), c.Len(), c.Len()))
+	reflect.Copy(s, c)
+	t.arrays[key] = &arrayEdit{path: append(pointer.Pointer{}, path...), slice: s}
+	return s
+}
+
+func (t *goTarget) apply(op JSONPatchOperation) error {
+	path, err := pointer.Parse(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Operation {
+	case "add", "replace":
+		value, err := toJSONValue(reflect.ValueOf(op.Value), op.Path, 0)
+		if err != nil {
+			return err
+		}
+		return t.set(path, value, op.Operation == "add")
+	case "remove":
+		return t.remove(path)
+	case "move", "copy":
+		from, err := pointer.Parse(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Operation == "move" && path.HasPrefix(from) {
+			if len(from) == len(path) {
+				return nil
+			}
+			return fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		// the json value is a copy that can be stored in a destination of a
+		// different type
+		value, err := t.get(from)
+		if err != nil {
+			return err
+		}
+		if op.Operation == "move" {
+			if err := t.remove(from); err != nil {
+				return err
+			}
+		}
+		return t.set(path, value, true)
+	case "test":
+		expected, err := toJSONValue(reflect.ValueOf(op.Value), op.Path, 0)
+		if err != nil {
+			return err
+		}
+		actual, err := t.get(path)
+		if err != nil {
+			return err
+		}
+		if !(&options{}).equal(actual, expected) {
+			return &TestFailedError{Path: op.Path, Expected: expected, Actual: actual}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", op.Operation)
+}
+
+// update walks down path from the settable value v and calls update with the
+// container holding the value path refers to. Containers that cannot be
+// modified in place, like map elements, are copied and stored back after
+// update returns. Arrays of fixed length are replaced by the slices they are
+// edited as, unless they are being copied back.
+func (t *goTarget) update(v reflect.Value, path pointer.Pointer, depth int, update func(c reflect.Value) error) error {
+	return withContainer(v, path, depth, func(c reflect.Value) error {
+		if c.Kind() == reflect.Array && !(t.flushing && depth == len(path)-1) {
+			c = t.arraySlice(c, path[:depth])
+		}
+		if depth == len(path)-1 {
+			return update(c)
+		}
+		token := path[depth]
+		switch c.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(c.Type(), token)
+			if !ok {
+				return pointerError(path, depth, pointer.ErrKeyNotFound)
+			}
+			fv, ok := fieldByIndex(c, f.index)
+			if !ok {
+				return pointerError(path, depth, pointer.ErrKeyNotFound)
+			}
+			return t.update(fv, path, depth+1, update)
+		case reflect.Map:
+			key, err := mapKeyValue(c.Type().Key(), token)
+			if err != nil {
+				return pointerError(path, depth, pointer.ErrKeyNotFound)
+			}
+			e := c.MapIndex(key)
+			if !e.IsValid() {
+				return pointerError(path, depth, pointer.ErrKeyNotFound)
+			}
+			cp := reflect.New(e.Type()).Elem()
+			cp.Set(e)
+			if err := t.update(cp, path, depth+1, update); err != nil {
+				return err
+			}
+			c.SetMapIndex(key, cp)
+			return nil
+		case reflect.Slice:
+			i, err := pointer.Index(token, c.Len())
+			if err != nil {
+				return pointerError(path, depth, err)
+			}
+			return t.update(c.Index(i), path, depth+1, update)
+		}
+		return pointerError(path, depth, pointer.ErrNotContainer)
+	})
+}
+
+// withContainer calls fn with the container v holds, following pointers and
+// interfaces. Values held by interfaces are copied to make them settable and
+// stored back afterwards.
+func withContainer(v reflect.Value, path pointer.Pointer, depth int, fn func(c reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return pointerError(path, depth, pointer.ErrNotContainer)
+		}
+		return withContainer(v.Elem(), path, depth, fn)
+	case reflect.Interface:
+		if v.IsNil() {
+			return pointerError(path, depth, pointer.ErrNotContainer)
+		}
+		c := reflect.New(v.Elem().Type()).Elem()
+		c.Set(v.Elem())
+		if err := withContainer(c, path, depth, fn); err != nil {
+			return err
+		}
+		v.Set(c)
+		return nil
+	}
+	return fn(v)
+}
+
+// get returns the value at path converted by toJSONValue.
+func (t *goTarget) get(path pointer.Pointer) (interface{}, error) {
+	if len(path) == 0 {
+		return toJSONValue(t.root, "", 0)
+	}
+	last := len(path) - 1
+	p := path.String()
+	var value interface{}
+	err := t.update(t.root, path, 0, func(c reflect.Value) error {
+		var err error
+		switch c.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(c.Type(), path[last])
+			if !ok {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			fv, ok := fieldByIndex(c, f.index)
+			if !ok {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			value, err = toJSONValue(fv, p, 0)
+			if err == nil && f.quoted {
+				value, err = quoteValue(value, p)
+			}
+			return err
+		case reflect.Map:
+			key, err := mapKeyValue(c.Type().Key(), path[last])
+			if err != nil || !c.MapIndex(key).IsValid() {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			value, err = toJSONValue(c.MapIndex(key), p, 0)
+			return err
+		case reflect.Slice:
+			i, err := pointer.Index(path[last], c.Len())
+			if err != nil {
+				return pointerError(path, last, err)
+			}
+			value, err = toJSONValue(c.Index(i), p, 0)
+			return err
+		}
+		return pointerError(path, last, pointer.ErrNotContainer)
+	})
+	return value, err
+}
+
+// set stores the json value j at path, converted to the type found there. If
+// add is set, elements are inserted into slices and map entries created,
+// otherwise the value at path must exist.
+func (t *goTarget) set(path pointer.Pointer, j interface{}, add bool) error {
+	if len(path) == 0 {
+		return assignJSONValue(t.root, j, "")
+	}
+	last := len(path) - 1
+	p := path.String()
+	return t.update(t.root, path, 0, func(c reflect.Value) error {
+		switch c.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(c.Type(), path[last])
+			if !ok {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			return assignField(fieldByIndexAlloc(c, f.index), f, j, p)
+		case reflect.Map:
+			key, err := mapKeyValue(c.Type().Key(), path[last])
+			if err != nil {
+				return &TypeMismatchError{Path: p, Value: path[last], Type: c.Type().Key(), Cause: err}
+			}
+			if !add && !c.MapIndex(key).IsValid() {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			e, err := fromJSONValue(j, c.Type().Elem(), p)
+			if err != nil {
+				return err
+			}
+			if c.IsNil() {
+				c.Set(reflect.MakeMap(c.Type()))
+			}
+			c.SetMapIndex(key, e)
+			return nil
+		case reflect.Slice:
+			n := c.Len()
+			if !add {
+				i, err := pointer.Index(path[last], n)
+				if err != nil {
+					return pointerError(path, last, err)
+				}
+				return assignJSONValue(c.Index(i), j, p)
+			}
+			i := n
+			if path[last] != "-" {
+				var err error
+				i, err = pointer.Index(path[last], n+1)
+				if err != nil {
+					return pointerError(path, last, err)
+				}
+			}
+			e, err := fromJSONValue(j, c.Type().Elem(), p)
+			if err != nil {
+				return err
+			}
+			s := reflect.Append(c, e)
+			reflect.Copy(s.Slice(i+1, n+1), s.Slice(i, n))
+			s.Index(i).Set(e)
+			c.Set(s)
+			return nil
+		}
+		return pointerError(path, last, pointer.ErrNotContainer)
+	})
+}
+
+func (t *goTarget) remove(path pointer.Pointer) error {
+	if len(path) == 0 {
+		return errors.New("cannot remove the whole document")
+	}
+	last := len(path) - 1
+	return t.update(t.root, path, 0, func(c reflect.Value) error {
+		switch c.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(c.Type(), path[last])
+			if !ok {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			fv, ok := fieldByIndex(c, f.index)
+			if !ok {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		case reflect.Map:
+			key, err := mapKeyValue(c.Type().Key(), path[last])
+			if err != nil || !c.MapIndex(key).IsValid() {
+				return pointerError(path, last, pointer.ErrKeyNotFound)
+			}
+			c.SetMapIndex(key, reflect.Value{})
+			return nil
+		case reflect.Slice:
+			i, err := pointer.Index(path[last], c.Len())
+			if err != nil {
+				return pointerError(path, last, err)
+			}
+			c.Set(reflect.AppendSlice(c.Slice(0, i), c.Slice(i+1, c.Len())))
+			return nil
+		}
+		return pointerError(path, last, pointer.ErrNotContainer)
+	})
+}
@@ -1,17 +1,286 @@
 package jsonpatch
 
 import (
-	"fmt"
+	"encoding/json"
 	"reflect"
+	"strconv"
+
+	"github.com/herkyl/jsonpatch/pointer"
 )
 
+// diffArrays compares a and b with the default options, see differ.diffArrays.
+func diffArrays(a, b []interface{}, p string, forceFullPatch bool) ([]JSONPatchOperation, error) {
+	return newDiffer().diffArrays(a, b, p, forceFullPatch)
+}
+
+// diffArrays returns the operations turning array a at path p into b. Unless
+// forceFullPatch is set, replacing the whole array is returned instead when
+// that is shorter.
+func (d *differ) diffArrays(a, b []interface{}, p string, forceFullPatch bool) ([]JSONPatchOperation, error) {
+	fullReplace := d.replace(nil, p, a, b)
+	if d.isPartial(p) {
+		d.trace("array", TraceStrategy, p, "by index, only some elements are included")
+		return d.diffArraysByIndex(a, b, p)
+	}
+	if key, ok := d.arrayKey(p); ok {
+		d.trace("array", TraceStrategy, p, "elements identified by member %q", key)
+		patch, err := d.diffArraysByKey(a, b, p, key)
+		if err != nil {
+			return nil, err
+		}
+		patch = d.useEndOfArray(patch, p, len(a))
+		if forceFullPatch {
+			return patch, nil
+		}
+		return d.smallest("array", p, fullReplace, patch), nil
+	}
+	if d.isUnordered(p) {
+		d.trace("array", TraceStrategy, p, "unordered, only membership changes")
+		patch := d.diffArraysUnordered(a, b, p)
+		if forceFullPatch {
+			return patch, nil
+		}
+		return d.smallest("array", p, fullReplace, patch), nil
+	}
+	script := editScriptFunc(len(a), len(b), func(i, j int) bool {
+		return d.equalElements(a[i], b[j], p, j)
+	})
+
+	var patch []JSONPatchOperation
+	if d.arrayStrategy == ArrayGreedy {
+		d.trace("array", TraceStrategy, p, "greedy")
+		patch = d.diffArraysGreedy(a, b, p)
+	} else {
+		d.trace("array", TraceStrategy, p, "longest common subsequence")
+		var err error
+		patch, err = d.diffArraysLCS(a, b, p, script)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if moves := d.diffArrayMoves(a, b, p, script); moves != nil {
+		if d.cost(moves) < d.cost(patch) {
+			d.trace("array", TraceMove, p, "%d operations with moves are shorter than %d without", len(moves), len(patch))
+			patch = moves
+		}
+	}
+	patch = d.useEndOfArray(patch, p, len(a))
+
+	if forceFullPatch {
+		return patch, nil
+	}
+	return d.smallest("array", p, fullReplace, patch), nil
+}
+
+// useEndOfArray rewrites the adds of patch appending elements to the array at
+// p, of length n in the original document, to use the "-" reference token if
+// WithEndOfArrayAdds is set. The length of the array is followed through the
+// operations on its elements.
+func (d *differ) useEndOfArray(patch []JSONPatchOperation, p string, n int) []JSONPatchOperation {
+	if !d.endOfArray {
+		return patch
+	}
+	parent, err := pointer.Parse(p)
+	if err != nil {
+		return patch
+	}
+	for i, op := range patch {
+		path, err := pointer.Parse(op.Path)
+		if err != nil || len(path) != len(parent)+1 || !path.HasPrefix(parent) {
+			continue
+		}
+		switch op.Operation {
+		case "add":
+			if path[len(parent)] == strconv.Itoa(n) {
+				patch[i].Path = makePath(p, "-")
+			}
+			n++
+		case "copy":
+			n++
+		case "remove":
+			n--
+		}
+	}
+	return patch
+}
+
+// diffArraysLCS turns script into operations. Within each run of changed
+// elements, an object or array replaced by another of the same kind is diffed
+// recursively instead of being removed and added again.
+func (d *differ) diffArraysLCS(a, b []interface{}, p string, script []edit) ([]JSONPatchOperation, error) {
+	patch := []JSONPatchOperation{}
+	// index is the position in the array as it is being patched
+	index, ai, bi := 0, 0, 0
+	for i := 0; i < len(script); {
+		if script[i] == editKeep {
+			index++
+			ai++
+			bi++
+			i++
+			continue
+		}
+		deleted, inserted := 0, 0
+		for ; i < len(script) && script[i] != editKeep; i++ {
+			if script[i] == editDelete {
+				deleted++
+			} else {
+				inserted++
+			}
+		}
+		for k := 0; k < deleted || k < inserted; k++ {
+			path := makePath(p, index)
+			switch {
+			case k < deleted && k < inserted && sameContainerKind(a[ai+k], b[bi+k]):
+				d.trace("array", TraceRecurse, path, "element %d changed in place", ai+k)
+				var err error
+				patch, err = d.diff(a[ai+k], b[bi+k], path, patch)
+				if err != nil {
+					return nil, err
+				}
+				index++
+			case k < deleted && k < inserted:
+				d.trace("array", TraceReplace, path, "element %d replaced by %v", ai+k, b[bi+k])
+				patch = d.remove(patch, path, a[ai+k])
+				patch = d.add(patch, path, b[bi+k])
+				index++
+			case k < deleted:
+				d.trace("array", TraceRemove, path, "element %d deleted", ai+k)
+				patch = d.remove(patch, path, a[ai+k])
+			default:
+				d.trace("array", TraceAdd, path, "%v inserted", b[bi+k])
+				patch = d.add(patch, path, b[bi+k])
+				index++
+			}
+		}
+		ai += deleted
+		bi += inserted
+	}
+	return patch, nil
+}
+
+func sameContainerKind(a, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}:
+		_, ok := b.(map[string]interface{})
+		return ok
+	case []interface{}:
+		_, ok := b.([]interface{})
+		return ok
+	}
+	return false
+}
+
+type edit int
+
+const (
+	editKeep edit = iota
+	editDelete
+	editInsert
+)
+
+// maxEditDistance bounds the number of differences editScript searches for,
+// the memory it needs grows with the square of it.
+const maxEditDistance = 1 << 10
+
+// editScript returns a shortest sequence of edits turning a into b, found with
+// the algorithm from Eugene W. Myers, "An O(ND) Difference Algorithm and Its
+// Variations". Deletions are ordered before insertions where both are
+// possible. If a and b differ in more than maxEditDistance elements every
+// element of a is deleted and every element of b inserted instead.
+func editScript(a, b []interface{}) []edit {
+	return editScriptFunc(len(a), len(b), func(i, j int) bool {
+		return reflect.DeepEqual(a[i], b[j])
+	})
+}
+
+// editScriptFunc is like editScript for sequences of length n and m whose
+// elements are compared by equal.
+func editScriptFunc(n, m int, equal func(i, j int) bool) []edit {
+	max := n + m
+	if max > maxEditDistance {
+		max = maxEditDistance
+	}
+	// v[offset+k] is the furthest x reached on diagonal k = x - y
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[offset-d : offset+d+1] after d differences
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && equal(x, y) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+	}
+	if !found {
+		script := make([]edit, 0, n+m)
+		for i := 0; i < n; i++ {
+			script = append(script, editDelete)
+		}
+		for j := 0; j < m; j++ {
+			script = append(script, editInsert)
+		}
+		return script
+	}
+
+	var script []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[d-1+k-1] < prev[d-1+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[d-1+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, editKeep)
+			x--
+			y--
+		}
+		if x == prevX {
+			script = append(script, editInsert)
+		} else {
+			script = append(script, editDelete)
+		}
+		x, y = prevX, prevY
+	}
+	for ; x > 0; x-- {
+		script = append(script, editKeep)
+	}
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
 type tmpEl struct {
 	val     interface{}
 	isFixed bool
 }
 
-func diffArrays(a, b []interface{}, p string, forceFullPatch bool) ([]JSONPatchOperation, error) {
-	fullReplace := []JSONPatchOperation{NewPatch("replace", p, b)}
+// diffArraysGreedy walks a and b in parallel, keeping elements of a that occur
+// at or after the same position in b in place.
+func (d *differ) diffArraysGreedy(a, b []interface{}, p string) []JSONPatchOperation {
 	patch := []JSONPatchOperation{}
 
 	tmp := make([]tmpEl, len(a))
@@ -29,66 +298,255 @@ func diffArrays(a, b []interface{}, p string, forceFullPatch bool) ([]JSONPatchO
 		tmp[i] = newEl
 	}
 	// Now we have an array of elements in which we know the original, unmoved elements
+	for i, te := range tmp {
+		if te.isFixed {
+			d.trace("array", TraceKeep, makePath(p, i), "%v occurs at or after the same position in b", te.val)
+		}
+	}
 
-	fmt.Println("a>>>", a)
-	fmt.Println("TMP>>>", tmp)
-
+	// bIndex is also the position in the array as it is being patched, the
+	// elements before it are those of b
 	aIndex := 0
 	bIndex := 0
-	addedDelta := 0
-	maxLen := len(a)
-	if len(b) > maxLen {
-		maxLen = len(b)
-	}
-	for aIndex+addedDelta < maxLen {
-		tmpIndex := aIndex + addedDelta
-		newPath := makePath(p, tmpIndex)
-		if aIndex >= len(a) && bIndex >= len(b) {
-			break
-		}
+	for aIndex < len(a) || bIndex < len(b) {
+		newPath := makePath(p, bIndex)
 		if aIndex >= len(a) { // a is out of bounds, all new items in b must be adds
-			patch = append(patch, NewPatch("add", newPath, b[tmpIndex]))
-			addedDelta++
+			d.trace("array", TraceAdd, newPath, "%v appended", b[bIndex])
+			patch = d.add(patch, newPath, b[bIndex])
+			bIndex++
 			continue
 		}
 		if bIndex >= len(b) { // b is out of bounds, all new items in a must be removed
-			patch = append(patch, NewPatch("remove", newPath, nil))
-			addedDelta--
+			d.trace("array", TraceRemove, newPath, "%v past the end of b", a[aIndex])
+			patch = d.remove(patch, newPath, a[aIndex])
 			aIndex++
 			continue
 		}
 		// can compare elements, so let's compare them
-		te := tmp[aIndex]
-		for j := bIndex; j < maxLen; j++ {
-			be := b[j]
-			fmt.Printf("Comparing i=%d j=%d ae=%v be=%v\n", aIndex, j, te.val, be)
-			if reflect.DeepEqual(te.val, be) {
-				// element is already in b, move on
-				bIndex++
-				aIndex++
+		te, be := tmp[aIndex], b[bIndex]
+		switch {
+		case reflect.DeepEqual(te.val, be):
+			// element is already in b, move on
+			bIndex++
+			aIndex++
+		case te.isFixed:
+			d.trace("array", TraceAdd, newPath, "%v inserted before fixed element %v", be, te.val)
+			patch = d.add(patch, newPath, be)
+			bIndex++
+		default:
+			d.trace("array", TraceRemove, newPath, "%v does not occur later in b", te.val)
+			patch = d.remove(patch, newPath, te.val)
+			aIndex++
+		}
+	}
+	return patch
+}
+
+// diffArrayMoves returns the operations turning a into b where elements that
+// were relocated are moved instead of removed and added again. Elements kept
+// by script stay in place. It returns nil when no element was relocated.
+func (d *differ) diffArrayMoves(a, b []interface{}, p string, script []edit) []JSONPatchOperation {
+	source, kept, moved := matchElements(a, b, script, func(i, j int) bool {
+		return d.equalElements(a[i], b[j], p, j)
+	})
+	for _, m := range moved {
+		if m {
+			return d.arrangeArray(a, b, p, source, kept, moved)
+		}
+	}
+	return nil
+}
+
+// diffArraysUnordered removes the elements of a that b lacks, from the last
+// one to the first so their indices stay valid, and appends the elements of
+// b that a lacks. Each element of a is matched with at most one equal element
+// of b.
+func (d *differ) diffArraysUnordered(a, b []interface{}, p string) []JSONPatchOperation {
+	matched := make([]bool, len(a))
+	var added []interface{}
+	for j, be := range b {
+		found := false
+		for i, ae := range a {
+			if !matched[i] && d.equalElements(ae, be, p, j) {
+				matched[i] = true
+				found = true
 				break
-			} else {
-				if te.isFixed {
-					fmt.Println("add", newPath, be)
-					patch = append(patch, NewPatch("add", newPath, be))
-					addedDelta++
-					bIndex++
-					break
-				} else {
-					fmt.Println("remove", newPath, be)
-					patch = append(patch, NewPatch("remove", newPath, nil))
-					addedDelta--
-					aIndex++
-					break
-				}
 			}
 		}
+		if !found {
+			added = append(added, be)
+		}
 	}
+	patch := []JSONPatchOperation{}
+	for i := len(a) - 1; i >= 0; i-- {
+		if !matched[i] {
+			d.trace("array", TraceRemove, makePath(p, i), "not a member any more")
+			patch = d.remove(patch, makePath(p, i), a[i])
+		}
+	}
+	for _, e := range added {
+		d.trace("array", TraceAdd, makePath(p, "-"), "new member")
+		patch = d.add(patch, makePath(p, "-"), e)
+	}
+	return patch
+}
 
-	fmt.Println("patch>>>", patch)
+// diffArraysByIndex diffs the elements of a and b at the same index, leaving
+// the elements only one of them has alone.
+func (d *differ) diffArraysByIndex(a, b []interface{}, p string) ([]JSONPatchOperation, error) {
+	patch := []JSONPatchOperation{}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		var err error
+		patch, err = d.diff(a[i], b[i], makePath(p, i), patch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return patch, nil
+}
 
-	if forceFullPatch {
-		return patch, nil
+// diffArraysByKey matches the elements of a and b by the value of their member
+// key. Matched elements are put in the order of b and then diffed recursively.
+func (d *differ) diffArraysByKey(a, b []interface{}, p string, key string) ([]JSONPatchOperation, error) {
+	ida := elementIdentities(a, key)
+	idb := elementIdentities(b, key)
+	same := func(i, j int) bool {
+		return ida[i] == idb[j]
+	}
+	source, kept, moved := matchElements(a, b, editScriptFunc(len(a), len(b), same), same)
+	patch := d.arrangeArray(a, b, p, source, kept, moved)
+	for j, i := range source {
+		if i < 0 || d.equal(a[i], b[j]) {
+			continue
+		}
+		var err error
+		patch, err = d.diff(a[i], b[j], makePath(p, j), patch)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return getSmallestPatch(fullReplace, patch), nil
+	return patch, nil
+}
+
+// elementIdentities returns the json encoding of the member key of every
+// element, or of the whole element if it is not an object with that member.
+func elementIdentities(elements []interface{}, key string) []string {
+	ids := make([]string, len(elements))
+	for i, e := range elements {
+		if o, ok := e.(map[string]interface{}); ok {
+			if id, ok := o[key]; ok {
+				b, _ := json.Marshal(id)
+				ids[i] = "key:" + string(b)
+				continue
+			}
+		}
+		b, _ := json.Marshal(e)
+		ids[i] = "value:" + string(b)
+	}
+	return ids
+}
+
+// matchElements decides which element of a ends up at each position of b.
+// source[j] is the index in a of the element ending up at b[j], or -1 if it is
+// new. Elements kept by script stay in place. Elements that script deletes are
+// moved to the position of the first element of b they are the same as and
+// that script inserts.
+func matchElements(a, b []interface{}, script []edit, same func(i, j int) bool) (source []int, kept, moved []bool) {
+	source = make([]int, len(b))
+	kept = make([]bool, len(a))
+	i, j := 0, 0
+	for _, e := range script {
+		switch e {
+		case editKeep:
+			source[j] = i
+			kept[i] = true
+			i++
+			j++
+		case editDelete:
+			i++
+		case editInsert:
+			source[j] = -1
+			j++
+		}
+	}
+
+	moved = make([]bool, len(a))
+	for j, i := range source {
+		if i >= 0 {
+			continue
+		}
+		for k := range a {
+			if !kept[k] && !moved[k] && same(k, j) {
+				source[j] = k
+				moved[k] = true
+				break
+			}
+		}
+	}
+	return source, kept, moved
+}
+
+// arrangeArray returns the operations putting the elements of a into the order
+// of b as described by source: elements neither kept nor moved are removed,
+// moved elements are moved and new elements of b are added.
+func (d *differ) arrangeArray(a, b []interface{}, p string, source []int, kept, moved []bool) []JSONPatchOperation {
+	patch := []JSONPatchOperation{}
+	// work tracks which element is where while the patch is applied, new
+	// elements are identified by their index in b offset by len(a)
+	work := make([]int, 0, len(a))
+	for i := len(a) - 1; i >= 0; i-- {
+		if !kept[i] && !moved[i] {
+			d.trace("array", TraceRemove, makePath(p, i), "element %d has no match", i)
+			patch = d.remove(patch, makePath(p, i), a[i])
+		}
+	}
+	for i := range a {
+		if kept[i] || moved[i] {
+			work = append(work, i)
+		}
+	}
+	for j, i := range source {
+		// the element for b[j] belongs right after the one for b[j-1]
+		dest := 0
+		if j > 0 {
+			prev := source[j-1]
+			if prev < 0 {
+				prev = len(a) + j - 1
+			}
+			dest = indexOf(work, prev) + 1
+		}
+		switch {
+		case i < 0:
+			d.trace("array", TraceAdd, makePath(p, dest), "element %d has no match", j)
+			patch = d.add(patch, makePath(p, dest), b[j])
+			work = insertAt(work, dest, len(a)+j)
+		case moved[i]:
+			from := indexOf(work, i)
+			if from < dest {
+				dest--
+			}
+			if from != dest {
+				d.trace("array", TraceMove, makePath(p, dest), "element %d relocated", i)
+				patch = append(patch, JSONPatchOperation{Operation: "move", From: makePath(p, from), Path: makePath(p, dest)})
+			}
+			work = insertAt(append(work[:from:from], work[from+1:]...), dest, i)
+		}
+	}
+	return patch
+}
+
+func indexOf(s []int, v int) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func insertAt(s []int, i int, v int) []int {
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
 }
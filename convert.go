@@ -0,0 +1,768 @@
+package jsonpatch
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CreatePatchFromValues creates a patch like CreatePatchWithOptions, turning
+// the Go value a into b without encoding them as json first, which makes it
+// about twice as fast as marshalling them and calling CreatePatchWithOptions.
+//
+// Values are converted the way json.Marshal would encode them: struct fields
+// are named and omitted according to their json tags, embedded structs are
+// flattened, and types implementing json.Marshaler or encoding.TextMarshaler
+// are encoded by their own methods. Numbers keep their Go type, so values in
+// the patch are exact.
+//
+// An *UnsupportedTypeError is returned for values json.Marshal rejects too,
+// like channels and functions.
+func CreatePatchFromValues(a, b interface{}, opts ...Option) ([]JSONPatchOperation, error) {
+	d := newDiffer(opts...)
+	if d.err != nil {
+		return nil, d.err
+	}
+	aI, err := toJSONValue(reflect.ValueOf(a), "", 0)
+	if err != nil {
+		return nil, err
+	}
+	bI, err := toJSONValue(reflect.ValueOf(b), "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return d.createCheckedPatch(aI, bI)
+}
+
+// maxValueDepth bounds the nesting of the values toJSONValue converts, deeper
+// values most likely contain a cycle.
+const maxValueDepth = 1000
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	numberType        = reflect.TypeOf(json.Number(""))
+)
+
+// toJSONValue converts v, found at path, into the representation of a decoded
+// json document, except that numbers keep their Go type.
+func toJSONValue(v reflect.Value, path string, depth int) (interface{}, error) {
+	j, err := convertValue(v, depth)
+	if err != nil {
+		return nil, err.at(path)
+	}
+	return j, nil
+}
+
+// convertError is an error converting the value found at the reference
+// tokens below the value given to toJSONValue. The tokens are collected while
+// the error unwinds, so paths are only formatted when a conversion fails.
+type convertError struct {
+	// tokens are in reverse order, the innermost first
+	tokens []string
+	err    func(path string) error
+}
+
+func failAt(err func(path string) error) *convertError {
+	return &convertError{err: err}
+}
+
+// below records that the failing value is below the reference token.
+func (e *convertError) below(token string) *convertError {
+	e.tokens = append(e.tokens, token)
+	return e
+}
+
+// at returns the error for the value given to toJSONValue at path.
+func (e *convertError) at(path string) error {
+	for i := len(e.tokens) - 1; i >= 0; i-- {
+		path = makePath(path, e.tokens[i])
+	}
+	return e.err(path)
+}
+
+// unsupported fails for the value v json.Marshal rejects.
+func unsupported(v reflect.Value) *convertError {
+	value := v.Interface()
+	return failAt(func(path string) error { return &UnsupportedTypeError{Path: path, Value: value} })
+}
+
+// marshalFailed fails for a value whose marshalling method returned err.
+func marshalFailed(err error) *convertError {
+	return failAt(func(path string) error { return fmt.Errorf("marshalling value at %q: %w", path, err) })
+}
+
+// convertValue is toJSONValue without building paths.
+func convertValue(v reflect.Value, depth int) (interface{}, *convertError) {
+	if depth > maxValueDepth {
+		return nil, failAt(func(path string) error {
+			return fmt.Errorf("value at %q is nested too deeply, it may contain a cycle", path)
+		})
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return convertValue(v.Elem(), depth+1)
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || (v.CanAddr() && reflect.PtrTo(t).Implements(marshalerType)) {
+		j, err := marshalValue(v)
+		if err != nil {
+			return nil, marshalFailed(err)
+		}
+		return j, nil
+	}
+	if t.Implements(textMarshalerType) || (v.CanAddr() && reflect.PtrTo(t).Implements(textMarshalerType)) {
+		j, err := marshalText(v)
+		if err != nil {
+			return nil, marshalFailed(err)
+		}
+		return j, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return convertValue(v.Elem(), depth+1)
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		if t == numberType {
+			n := json.Number(v.String())
+			if n == "" {
+				n = "0"
+			}
+			if !isJSONNumber(string(n)) {
+				return nil, unsupported(v)
+			}
+			return n, nil
+		}
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		n := float32(v.Float())
+		if _, ok := toNumber(n); !ok {
+			return nil, unsupported(v)
+		}
+		return n, nil
+	case reflect.Float64:
+		n := v.Float()
+		if _, ok := toNumber(n); !ok {
+			return nil, unsupported(v)
+		}
+		return n, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(t.Elem()).Implements(marshalerType) && !reflect.PtrTo(t.Elem()).Implements(textMarshalerType) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		return convertSlice(v, depth)
+	case reflect.Array:
+		return convertSlice(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, unsupported(v)
+			}
+			e, cerr := convertValue(iter.Value(), depth+1)
+			if cerr != nil {
+				return nil, cerr.below(key)
+			}
+			m[key] = e
+		}
+		return m, nil
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		for _, f := range cachedTypeFields(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			e, err := convertValue(fv, depth+1)
+			if err != nil {
+				return nil, err.below(f.name)
+			}
+			if f.quoted {
+				var qerr error
+				e, qerr = quoteScalar(e)
+				if qerr != nil {
+					return nil, marshalFailed(qerr).below(f.name)
+				}
+			}
+			m[f.name] = e
+		}
+		return m, nil
+	}
+	return nil, unsupported(v)
+}
+
+func convertSlice(v reflect.Value, depth int) (interface{}, *convertError) {
+	s := make([]interface{}, v.Len())
+	for i := range s {
+		e, err := convertValue(v.Index(i), depth+1)
+		if err != nil {
+			return nil, err.below(strconv.Itoa(i))
+		}
+		s[i] = e
+	}
+	return s, nil
+}
+
+// marshalValue converts v by calling its MarshalJSON method.
+func marshalValue(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	m, ok := v.Interface().(json.Marshaler)
+	if !ok {
+		m = v.Addr().Interface().(json.Marshaler)
+	}
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var e interface{}
+	err = unmarshalUseNumber(b, &e)
+	return e, err
+}
+
+// marshalText converts v by calling its MarshalText method.
+func marshalText(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		m = v.Addr().Interface().(encoding.TextMarshaler)
+	}
+	b, err := m.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// mapKey returns the object member name json.Marshal uses for the map key k.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", k.Type())
+}
+
+// quoteValue applies the ",string" tag option to the converted scalar e found
+// at path.
+func quoteValue(e interface{}, path string) (interface{}, error) {
+	q, err := quoteScalar(e)
+	if err != nil {
+		return nil, marshalFailed(err).at(path)
+	}
+	return q, nil
+}
+
+// quoteScalar is quoteValue without the path.
+func quoteScalar(e interface{}) (interface{}, error) {
+	switch e.(type) {
+	case nil, []interface{}, map[string]interface{}:
+		return e, nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// fieldByIndex returns the field of struct v at index, or false if it is in
+// an embedded struct behind a nil pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// field is a struct field encoded as an object member.
+type field struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedTypeFields is typeFields with the result cached per type.
+func cachedTypeFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields returns the fields json.Marshal encodes for struct type t,
+// following the same rules: fields of embedded structs are promoted, and of
+// several fields with the same name the least nested one wins, or the one with
+// a json tag among equally nested ones. Other conflicting fields are dropped.
+func typeFields(t reflect.Type) []field {
+	var all []field
+	collectFields(t, nil, map[reflect.Type]bool{}, &all)
+
+	byName := map[string][]field{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var fields []field
+	for _, candidates := range byName {
+		if f, ok := dominantField(candidates); ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, visiting map[reflect.Type]bool, fields *[]field) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous {
+			if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+				// embedded fields of unexported non-struct types are ignored
+				continue
+			}
+		} else if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+			collectFields(ft, fieldIndex, visiting, fields)
+			continue
+		}
+		f := field{name: name, index: fieldIndex, tagged: name != ""}
+		if name == "" {
+			f.name = sf.Name
+		}
+		f.omitEmpty = strings.Contains(opts, ",omitempty")
+		if strings.Contains(opts, ",string") {
+			switch ft.Kind() {
+			case reflect.Bool, reflect.String,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64:
+				f.quoted = true
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// dominantField returns the field winning among fields of the same name.
+func dominantField(fields []field) (field, bool) {
+	depth := len(fields[0].index)
+	for _, f := range fields {
+		if len(f.index) < depth {
+			depth = len(f.index)
+		}
+	}
+	var winner field
+	found, tagged := 0, 0
+	for _, f := range fields {
+		if len(f.index) != depth {
+			continue
+		}
+		found++
+		if f.tagged {
+			tagged++
+			winner = f
+		} else if tagged == 0 {
+			winner = f
+		}
+	}
+	if found == 1 || tagged == 1 {
+		return winner, true
+	}
+	return field{}, false
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// fromJSONValue converts j, a value as returned by toJSONValue, into a new
+// value of type t the way json.Unmarshal would decode it. path is reported in
+// errors.
+func fromJSONValue(j interface{}, t reflect.Type, path string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	err := assignJSONValue(v, j, path)
+	return v, err
+}
+
+// assignJSONValue stores j, converted to the type of v, in v. A null sets v to
+// its zero value. Structs keep the values of fields that are not encoded.
+func assignJSONValue(v reflect.Value, j interface{}, path string) error {
+	mismatch := func(cause error) error {
+		return &TypeMismatchError{Path: path, Value: j, Type: v.Type(), Cause: cause}
+	}
+	if j == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+		b, err := json.Marshal(j)
+		if err != nil {
+			return mismatch(err)
+		}
+		if err := v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b); err != nil {
+			return mismatch(err)
+		}
+		return nil
+	}
+	if s, ok := j.(string); ok && v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return mismatch(err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		// like json.Unmarshal, the value pointed to is reused
+		if !v.IsNil() {
+			return assignJSONValue(v.Elem(), j, path)
+		}
+		e := reflect.New(v.Type().Elem())
+		if err := assignJSONValue(e.Elem(), j, path); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(nil)
+		}
+		v.Set(reflect.ValueOf(plainJSONValue(j)))
+		return nil
+	case reflect.Bool:
+		b, ok := j.(bool)
+		if !ok {
+			return mismatch(nil)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		if v.Type() == numberType && kindOf(j) == kindNumber {
+			b, err := json.Marshal(j)
+			if err != nil {
+				return mismatch(err)
+			}
+			v.SetString(string(b))
+			return nil
+		}
+		s, ok := j.(string)
+		if !ok {
+			return mismatch(nil)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toNumber(j)
+		if !ok || !n.IsInt() {
+			return mismatch(nil)
+		}
+		i, acc := n.Int64()
+		if acc != big.Exact || v.OverflowInt(i) {
+			return mismatch(nil)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toNumber(j)
+		if !ok || !n.IsInt() {
+			return mismatch(nil)
+		}
+		u, acc := n.Uint64()
+		if acc != big.Exact || v.OverflowUint(u) {
+			return mismatch(nil)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := toNumber(j)
+		if !ok {
+			return mismatch(nil)
+		}
+		f, _ := n.Float64()
+		if v.OverflowFloat(f) {
+			return mismatch(nil)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if s, ok := j.(string); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return mismatch(err)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		a, ok := j.([]interface{})
+		if !ok {
+			return mismatch(nil)
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := assignJSONValue(s.Index(i), e, makePath(path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		a, ok := j.([]interface{})
+		if !ok || len(a) > v.Len() {
+			return mismatch(nil)
+		}
+		for i := 0; i < v.Len(); i++ {
+			var e interface{}
+			if i < len(a) {
+				e = a[i]
+			}
+			if err := assignJSONValue(v.Index(i), e, makePath(path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := j.(map[string]interface{})
+		if !ok {
+			return mismatch(nil)
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), len(m))
+		for name, e := range m {
+			key, err := mapKeyValue(v.Type().Key(), name)
+			if err != nil {
+				return mismatch(err)
+			}
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := assignJSONValue(ev, e, makePath(path, name)); err != nil {
+				return err
+			}
+			mv.SetMapIndex(key, ev)
+		}
+		v.Set(mv)
+		return nil
+	case reflect.Struct:
+		m, ok := j.(map[string]interface{})
+		if !ok {
+			return mismatch(nil)
+		}
+		// fields json does not know about, like unexported ones, are kept
+		for _, f := range cachedTypeFields(v.Type()) {
+			if fv, ok := fieldByIndex(v, f.index); ok {
+				fv.Set(reflect.Zero(fv.Type()))
+			}
+		}
+		for name, e := range m {
+			f, ok := fieldByName(v.Type(), name)
+			if !ok {
+				// like json.Unmarshal, unknown members are ignored
+				continue
+			}
+			if err := assignField(fieldByIndexAlloc(v, f.index), f, e, makePath(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return mismatch(nil)
+}
+
+// assignField stores j in the struct field fv described by f, decoding it
+// first if the field has the ",string" option.
+func assignField(fv reflect.Value, f field, j interface{}, path string) error {
+	if f.quoted && j != nil {
+		s, ok := j.(string)
+		if !ok {
+			return &TypeMismatchError{Path: path, Value: j, Type: fv.Type()}
+		}
+		j = nil
+		if err := unmarshalUseNumber([]byte(s), &j); err != nil {
+			return &TypeMismatchError{Path: path, Value: s, Type: fv.Type(), Cause: err}
+		}
+	}
+	return assignJSONValue(fv, j, path)
+}
+
+// plainJSONValue returns j with all numbers turned into float64, as
+// json.Unmarshal decodes them into an interface{}.
+func plainJSONValue(j interface{}) interface{} {
+	switch jt := j.(type) {
+	case []interface{}:
+		a := make([]interface{}, len(jt))
+		for i, e := range jt {
+			a[i] = plainJSONValue(e)
+		}
+		return a
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(jt))
+		for key, e := range jt {
+			m[key] = plainJSONValue(e)
+		}
+		return m
+	}
+	if n, ok := toNumber(j); ok {
+		f, _ := n.Float64()
+		return f
+	}
+	return j
+}
+
+// mapKeyValue converts the object member name s into a map key of type t.
+func mapKeyValue(t reflect.Type, s string) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		k := reflect.New(t)
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, err
+		}
+		return k.Elem(), nil
+	}
+	k := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		k.SetString(s)
+		return k, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || k.OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", s, t)
+		}
+		k.SetInt(i)
+		return k, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || k.OverflowUint(u) {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", s, t)
+		}
+		k.SetUint(u)
+		return k, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported map key type %s", t)
+}
+
+// fieldByName returns the field of struct type t encoded as the object
+// member name. Like json.Unmarshal it prefers an exact match but falls back
+// to a case-insensitive one.
+func fieldByName(t reflect.Type, name string) (field, bool) {
+	fields := cachedTypeFields(t)
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// fieldByIndexAlloc returns the field of the addressable struct v at index,
+// allocating embedded structs behind nil pointers on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// CostFunc measures a list of operations. The costs of the operations changing
+// an object or array and of the single operation replacing it decide which of
+// them ends up in the patch, see WithCostFunc.
+type CostFunc func(patch []JSONPatchOperation) float64
+
+// CostBytes is the length of patch encoded as json, the default CostFunc.
+func CostBytes(patch []JSONPatchOperation) float64 {
+	return float64(patchSize(patch))
+}
+
+// CostOperations is the number of operations in patch.
+func CostOperations(patch []JSONPatchOperation) float64 {
+	return float64(len(patch))
+}
+
+// CostDepthWeighted counts every operation of patch as one plus 2^-n, where n
+// is the number of reference tokens in its path, so operations closer to the
+// root, which replace more of the document, weigh more. A single operation
+// never costs more than replacing one of its ancestors, while several
+// operations below a value do.
+func CostDepthWeighted(patch []JSONPatchOperation) float64 {
+	cost := 0.0
+	for _, op := range patch {
+		p, err := pointer.Parse(op.Path)
+		if err != nil {
+			cost += 2
+			continue
+		}
+		cost += 1 + math.Ldexp(1, -len(p))
+	}
+	return cost
+}
+
+// WithCostFunc selects how operations are measured when deciding whether the
+// changes to an object or array are collapsed into a single replace of the
+// whole value, CostBytes by default. The same measure decides whether array
+// elements are moved rather than removed and added again. Unlike with the
+// default, operations costing exactly as much as the replace are kept, so a
+// single changed value is not replaced together with its parent. A nil f
+// restores the default.
+func WithCostFunc(f CostFunc) Option {
+	return func(o *options) {
+		o.cost, o.costTiesReplace = f, f == nil
+		if f == nil {
+			o.cost = CostBytes
+		}
+	}
+}
+
+// WithReplaceThreshold sets how much more the operations changing an object
+// or array must cost than replacing it for the replace to be used instead:
+// the changes are collapsed when their cost exceeds threshold times the cost
+// of the replace, or equals it with the default CostFunc. The default of 1
+// picks the cheaper of both, larger values keep more fine-grained operations
+// and 0 always replaces.
+func WithReplaceThreshold(threshold float64) Option {
+	return func(o *options) {
+		if threshold < 0 || math.IsNaN(threshold) {
+			o.setErr(fmt.Errorf("invalid replace threshold %v", threshold))
+			return
+		}
+		o.replaceThreshold = threshold
+	}
+}
@@ -0,0 +1,92 @@
+package jsonpatch
+
+import (
+	"strings"
+	"time"
+)
+
+// Equaler decides whether two values found at the same path of both documents
+// are the same, see WithEqualer. The values are decoded like the documents,
+// so numbers are float64 or json.Number depending on the NumberMode.
+type Equaler interface {
+	Equal(a, b interface{}) bool
+}
+
+// EqualerFunc adapts an ordinary function to the Equaler interface.
+type EqualerFunc func(a, b interface{}) bool
+
+// Equal calls f(a, b).
+func (f EqualerFunc) Equal(a, b interface{}) bool {
+	return f(a, b)
+}
+
+// EqualWithin returns an Equaler treating numbers that differ by no more than
+// tolerance as equal, also inside objects and arrays such as GeoJSON
+// coordinates. Other values must be the same.
+func EqualWithin(tolerance float64) Equaler {
+	o := &options{floatTolerance: tolerance}
+	return EqualerFunc(o.equal)
+}
+
+// EqualFold returns an Equaler comparing strings case-insensitively, e.g. for
+// email addresses. Other values must be the same.
+func EqualFold() Equaler {
+	return EqualerFunc(func(a, b interface{}) bool {
+		as, aok := a.(string)
+		bs, bok := b.(string)
+		if aok && bok {
+			return strings.EqualFold(as, bs)
+		}
+		return (&options{}).equal(a, b)
+	})
+}
+
+// EqualTime returns an Equaler comparing strings holding timestamps in the
+// given layout, like time.RFC3339, by the instant they denote, so the same
+// time in different time zones is equal. Values that are not such strings
+// must be the same.
+func EqualTime(layout string) Equaler {
+	return EqualerFunc(func(a, b interface{}) bool {
+		as, aok := a.(string)
+		bs, bok := b.(string)
+		if aok && bok {
+			at, aerr := time.Parse(layout, as)
+			bt, berr := time.Parse(layout, bs)
+			if aerr == nil && berr == nil {
+				return at.Equal(bt)
+			}
+		}
+		return (&options{}).equal(a, b)
+	})
+}
+
+// WithEqualer compares the values at the paths matched by the path pattern,
+// like "/sensors/*/reading", with e. Values e reports as equal produce no
+// operations, others are compared as usual. When several patterns match a
+// path, the first one given is used.
+func WithEqualer(pattern string, e Equaler) Option {
+	return func(o *options) {
+		pp, err := parsePattern(pattern)
+		if err != nil {
+			o.setErr(err)
+			return
+		}
+		o.equalers = append(o.equalers, pathEqualer{pattern: pp, equaler: e})
+	}
+}
+
+type pathEqualer struct {
+	pattern pathPattern
+	equaler Equaler
+}
+
+// equalByEqualer reports whether the Equaler configured for path considers a
+// and b equal. It is false if there is none.
+func (o *options) equalByEqualer(a, b interface{}, path string) bool {
+	for _, pe := range o.equalers {
+		if pe.pattern.match(path) {
+			return pe.equaler.Equal(a, b)
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// InvalidDocumentError is returned when a json document cannot be decoded.
+type InvalidDocumentError struct {
+	// Which names the document: "original" for the document a patch is
+	// created from or applied to, "modified" for the document a patch is
+	// created for, or "patch" for a patch document or merge patch.
+	Which string
+	// Offset is the number of bytes read before the error was found, or -1
+	// if it is not known.
+	Offset int64
+	// Cause is the error returned by the json decoder.
+	Cause error
+}
+
+func (e *InvalidDocumentError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("invalid %s JSON document: %v", e.Which, e.Cause)
+	}
+	return fmt.Sprintf("invalid %s JSON document at offset %d: %v", e.Which, e.Offset, e.Cause)
+}
+
+func (e *InvalidDocumentError) Unwrap() error {
+	return e.Cause
+}
+
+// decodeDocument decodes the json document data, which names it in the
+// returned error. Numbers are decoded as json.Number if useNumber is set,
+// float64 otherwise.
+func decodeDocument(data []byte, which string, useNumber bool) (interface{}, error) {
+	var v interface{}
+	var err error
+	if useNumber {
+		err = unmarshalUseNumber(data, &v)
+	} else {
+		err = json.Unmarshal(data, &v)
+	}
+	if err != nil {
+		return nil, documentError(which, err)
+	}
+	return v, nil
+}
+
+// documentError wraps err, returned by the json decoder for the document
+// which, into an InvalidDocumentError.
+func documentError(which string, err error) error {
+	offset := int64(-1)
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	}
+	return &InvalidDocumentError{Which: which, Offset: offset, Cause: err}
+}
+
+// PathNotFoundError is returned when an operation refers to a value that does
+// not exist in the document it is applied to.
+type PathNotFoundError struct {
+	// Index is the position of the operation in the patch.
+	Index int
+	// Operation is the op of the operation, like "remove".
+	Operation string
+	// Path is the JSON Pointer that could not be resolved, the operation's
+	// path or from.
+	Path string
+	// Cause is the *pointer.Error describing which reference token failed.
+	Cause error
+}
+
+func (e *PathNotFoundError) Error() string {
+	return fmt.Sprintf("operation %d (%s %q): %v", e.Index, e.Operation, e.Path, e.Cause)
+}
+
+func (e *PathNotFoundError) Unwrap() error {
+	return e.Cause
+}
+
+// TestFailedError is returned when the value tested by a test operation
+// differs from the value in the document.
+type TestFailedError struct {
+	// Index is the position of the operation in the patch.
+	Index int
+	// Path is the JSON Pointer of the tested value.
+	Path string
+	// Expected is the value of the test operation, Actual the one found in
+	// the document.
+	Expected, Actual interface{}
+}
+
+func (e *TestFailedError) Error() string {
+	return fmt.Sprintf("operation %d (test %q): test failed, value is %v", e.Index, e.Path, e.Actual)
+}
+
+// InvalidOperationError is returned for operations that cannot be carried
+// out whatever the document, like unknown ops, malformed pointers or moving a
+// value into one of its own children.
+type InvalidOperationError struct {
+	// Index is the position of the operation in the patch.
+	Index int
+	// Operation is the op of the operation, like "move".
+	Operation string
+	// Path is the path of the operation.
+	Path string
+	// Cause describes what is wrong with the operation.
+	Cause error
+}
+
+func (e *InvalidOperationError) Error() string {
+	return fmt.Sprintf("operation %d (%s %q): %v", e.Index, e.Operation, e.Path, e.Cause)
+}
+
+func (e *InvalidOperationError) Unwrap() error {
+	return e.Cause
+}
+
+// UnsupportedTypeError is returned when a document holds a Go value that has
+// no JSON representation, like a channel or a NaN float.
+type UnsupportedTypeError struct {
+	// Path is the JSON Pointer of the value.
+	Path string
+	// Value is the unsupported value.
+	Value interface{}
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported value %v of type %T at %q", e.Value, e.Value, e.Path)
+}
+
+// TypeMismatchError is returned by ApplyTo when a value cannot be stored in the
+// Go value at its path, like a string in an int field.
+type TypeMismatchError struct {
+	// Path is the JSON Pointer of the destination.
+	Path string
+	// Value is the value that could not be stored.
+	Value interface{}
+	// Type is the Go type of the destination.
+	Type reflect.Type
+	// Cause is the error returned by the destination's UnmarshalJSON or
+	// UnmarshalText method, if any.
+	Cause error
+}
+
+func (e *TypeMismatchError) Error() string {
+	s := fmt.Sprintf("cannot store %v of type %T in %s at %q", e.Value, e.Value, e.Type, e.Path)
+	if e.Cause != nil {
+		s += ": " + e.Cause.Error()
+	}
+	return s
+}
+
+func (e *TypeMismatchError) Unwrap() error {
+	return e.Cause
+}
+
+// operationError turns err, returned while applying the operation op at index
+// i of a patch, into a PathNotFoundError, TestFailedError or
+// InvalidOperationError. Only pointers that are well formed but do not resolve
+// in the document make a PathNotFoundError, malformed ones like "/a/01" or a
+// "-" where an existing element is needed make the operation invalid.
+func operationError(i int, op JSONPatchOperation, err error) error {
+	var testErr *TestFailedError
+	if errors.As(err, &testErr) {
+		testErr.Index = i
+		return testErr
+	}
+	var ptrErr *pointer.Error
+	if errors.As(err, &ptrErr) && isMissingPath(err) {
+		return &PathNotFoundError{Index: i, Operation: op.Operation, Path: ptrErr.Pointer, Cause: err}
+	}
+	return &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: err}
+}
+
+// isMissingPath reports whether err is a pointer error caused by the document
+// rather than by the pointer itself.
+func isMissingPath(err error) bool {
+	return errors.Is(err, pointer.ErrKeyNotFound) ||
+		errors.Is(err, pointer.ErrIndexOutOfRange) ||
+		errors.Is(err, pointer.ErrNotContainer)
+}
@@ -0,0 +1,71 @@
+package jsonpatch
+
+import (
+	"sort"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// guardParents prepends test operations asserting the value in the original
+// document a of every container changed by patch. A container below another
+// one that is tested already is left out, as are the elements of arrays: their
+// indices shift as the patch is applied, so the array itself is tested.
+func guardParents(a interface{}, patch []JSONPatchOperation) []JSONPatchOperation {
+	guarded := map[string]pointer.Pointer{}
+	for _, op := range patch {
+		paths := []string{op.Path}
+		if op.Operation == "move" {
+			paths = append(paths, op.From)
+		}
+		for _, path := range paths {
+			p, err := pointer.Parse(path)
+			if err != nil {
+				continue
+			}
+			parent, _, ok := p.Parent()
+			if !ok {
+				// the whole document is replaced, test all of it
+				parent = p
+			}
+			guard := stableContainer(a, parent)
+			guarded[guard.String()] = guard
+		}
+	}
+
+	paths := make([]string, 0, len(guarded))
+	for path := range guarded {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	tests := []JSONPatchOperation{}
+	var last pointer.Pointer
+	for _, path := range paths {
+		p := guarded[path]
+		if last != nil && p.HasPrefix(last) {
+			continue
+		}
+		last = p
+		v, err := p.Eval(a)
+		if err != nil {
+			continue
+		}
+		tests = append(tests, NewPatch("test", path, v))
+	}
+	return append(tests, patch...)
+}
+
+// stableContainer returns the longest prefix of p that only passes through
+// objects in doc, so it refers to the same value until the patch is applied.
+func stableContainer(doc interface{}, p pointer.Pointer) pointer.Pointer {
+	for i, token := range p {
+		o, ok := doc.(map[string]interface{})
+		if !ok {
+			return p[:i]
+		}
+		doc, ok = o[token]
+		if !ok {
+			return p[:i]
+		}
+	}
+	return p
+}
@@ -0,0 +1,53 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Invert returns the patch undoing patch: applying patch to a document and
+// then its inverse yields the original document again.
+//
+// Undoing replace and remove operations requires the values they overwrote.
+// They are taken from OldValue, recorded by CreatePatchWithOptions with
+// WithOldValues, or from a test operation of the same path directly before
+// the operation, as emitted with WithTestOperations. Test operations
+// themselves are dropped from the inverse.
+//
+// An *InvalidOperationError is returned for operations whose effect cannot be
+// undone without the document, like adding to the end of an array with "-" or
+// a replace or remove whose old value is not known.
+func Invert(patch []JSONPatchOperation) ([]JSONPatchOperation, error) {
+	inverse := make([]JSONPatchOperation, 0, len(patch))
+	for i := len(patch) - 1; i >= 0; i-- {
+		op := patch[i]
+		old, hasOld := op.OldValue, op.HasOldValue
+		if i > 0 && patch[i-1].Operation == "test" && patch[i-1].Path == op.Path {
+			old, hasOld = patch[i-1].Value, true
+		}
+		if !hasOld && (op.Operation == "remove" || op.Operation == "replace") {
+			return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: errors.New("cannot invert without the old value, see WithOldValues")}
+		}
+		switch op.Operation {
+		case "add", "copy":
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: errors.New("cannot invert adding to the end of an array")}
+			}
+			inverse = append(inverse, NewPatch("remove", op.Path, nil))
+		case "remove":
+			inverse = append(inverse, NewPatch("add", op.Path, old))
+		case "replace":
+			inverse = append(inverse, NewPatch("replace", op.Path, old))
+		case "move":
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: errors.New("cannot invert moving to the end of an array")}
+			}
+			inverse = append(inverse, JSONPatchOperation{Operation: "move", From: op.Path, Path: op.From})
+		case "test":
+		default:
+			return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: fmt.Errorf("unknown operation %q", op.Operation)}
+		}
+	}
+	return inverse, nil
+}
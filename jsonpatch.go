@@ -5,15 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strings"
-)
+	"sort"
 
-var errBadJSONDoc = fmt.Errorf("Invalid JSON Document")
+	"github.com/herkyl/jsonpatch/pointer"
+)
 
 type JSONPatchOperation struct {
 	Operation string      `json:"op"`
 	Path      string      `json:"path"`
+	From      string      `json:"from,omitempty"`
 	Value     interface{} `json:"value,omitempty"`
+	// OldValue is the value replaced or removed by the operation. It is only
+	// recorded by CreatePatchWithOptions with WithOldValues and never encoded.
+	OldValue interface{} `json:"-"`
+	// HasOldValue reports whether OldValue was recorded, which tells a
+	// recorded null from no value at all.
+	HasOldValue bool `json:"-"`
 }
 
 func (j *JSONPatchOperation) JSON() string {
@@ -23,22 +30,76 @@ func (j *JSONPatchOperation) JSON() string {
 
 func (j *JSONPatchOperation) MarshalJSON() ([]byte, error) {
 	var b bytes.Buffer
-	b.WriteString("{")
-	b.WriteString(fmt.Sprintf(`"op":"%s"`, j.Operation))
-	b.WriteString(fmt.Sprintf(`,"path":"%s"`, j.Path))
+	err := j.encode(&b)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// encode appends the json encoding of j to b.
+func (j *JSONPatchOperation) encode(b *bytes.Buffer) error {
+	b.WriteString(`{"op":`)
+	encodeString(b, j.Operation)
+	b.WriteString(`,"path":`)
+	encodeString(b, j.Path)
+	if j.From != "" || j.Operation == "move" || j.Operation == "copy" {
+		b.WriteString(`,"from":`)
+		encodeString(b, j.From)
+	}
 	// Consider omitting Value for non-nullable operations.
-	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" {
+	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" || j.Operation == "test" {
 		v, err := json.Marshal(j.Value)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		b.WriteString(`,"value":`)
 		b.Write(v)
 	}
 	b.WriteString("}")
-	return b.Bytes(), nil
+	return nil
+}
+
+// encodeString appends s to b as a json string, escaping quotes, backslashes
+// and control characters.
+func encodeString(b *bytes.Buffer, s string) {
+	v, _ := json.Marshal(s) // never fails for a string
+	b.Write(v)
 }
 
+// UnmarshalJSON decodes a single operation of a patch document. It only checks
+// that the members required by the operation are present, the operation itself
+// is validated when the patch is applied. Numbers in the value are decoded as
+// float64 like by json.Unmarshal, DecodePatch keeps them exact as json.Number.
+func (j *JSONPatchOperation) UnmarshalJSON(data []byte) error {
+	op, err := decodeOperation(data, false)
+	if err != nil {
+		return err
+	}
+	*j = op
+	return nil
+}
+
+func unmarshalMember(members map[string]json.RawMessage, name string, s *string) error {
+	v, ok := members[name]
+	if !ok || string(v) == "null" {
+		return fmt.Errorf("missing '%s' member", name)
+	}
+	err := json.Unmarshal(v, s)
+	if err != nil {
+		return fmt.Errorf("invalid '%s' member: %v", name, err)
+	}
+	return nil
+}
+
+// Patch is a JSON Patch document, the list of operations applied in order.
+// The functions of this package accept and return []JSONPatchOperation, which
+// converts to and from Patch without copying:
+//
+//	ops, err := CreatePatch(a, b)
+//	undo, err := Patch(ops).Invert()
+type Patch []JSONPatchOperation
+
 type ByPath []JSONPatchOperation
 
 func (a ByPath) Len() int           { return len(a) }
@@ -54,139 +115,328 @@ func NewPatch(operation, path string, value interface{}) JSONPatchOperation {
 // 'a' is original, 'b' is the modified document. Both are to be given as json encoded content.
 // The function will return an array of JSONPatchOperations
 //
-// An error will be returned if any of the two documents are invalid.
+// An *InvalidDocumentError will be returned if any of the two documents are invalid.
 func CreatePatch(a, b []byte) ([]JSONPatchOperation, error) {
-	var aI interface{}
-	var bI interface{}
+	return CreatePatchWithOptions(a, b)
+}
 
-	err := json.Unmarshal(a, &aI)
-	if err != nil {
-		return nil, errBadJSONDoc
+// CreatePatchWithOptions creates a patch like CreatePatch, configured by opts.
+// Without options arrays are compared with ArrayLCS and changes are collapsed
+// into a single replace wherever that is shorter.
+//
+// An error will be returned if any of the two documents or options are invalid.
+func CreatePatchWithOptions(a, b []byte, opts ...Option) ([]JSONPatchOperation, error) {
+	d := newDiffer(opts...)
+	if d.err != nil {
+		return nil, d.err
 	}
-	err = json.Unmarshal(b, &bI)
+	aI, bI, err := decodeDocuments(a, b, d.numberMode != NumberFloat64)
 	if err != nil {
-		return nil, errBadJSONDoc
+		return nil, err
 	}
-
-	return diff(aI, bI, "", []JSONPatchOperation{})
+	return d.createCheckedPatch(aI, bI)
 }
 
-// From http://tools.ietf.org/html/rfc6901#section-4 :
-//
-// Evaluation of each reference token begins by decoding any escaped
-// character sequence.  This is performed by first transforming any
-// occurrence of the sequence '~1' to '/', and then transforming any
-// occurrence of the sequence '~0' to '~'.
-//   TODO decode support:
-//   var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
+// createPatch returns the patch turning the decoded document a into b.
+func (d *differ) createPatch(a, b interface{}) ([]JSONPatchOperation, error) {
+	if err := checkValue(a, ""); err != nil {
+		return nil, err
+	}
+	if err := checkValue(b, ""); err != nil {
+		return nil, err
+	}
+	return d.createCheckedPatch(a, b)
+}
 
-var rfc6901Encoder = strings.NewReplacer("~", "~0", "/", "~1")
+// createCheckedPatch is createPatch for documents known to hold json values
+// only, like decoded documents and Go values converted by toJSONValue.
+func (d *differ) createCheckedPatch(a, b interface{}) ([]JSONPatchOperation, error) {
+	patch, err := d.diff(a, b, "", []JSONPatchOperation{})
+	if err != nil {
+		return nil, err
+	}
+	patch = useCopies(a, b, patch)
+	if d.parentTests && len(patch) > 0 {
+		patch = guardParents(a, patch)
+	}
+	return patch, nil
+}
 
-func makePath(path string, newPart interface{}) string {
-	key := rfc6901Encoder.Replace(fmt.Sprintf("%v", newPart))
-	if path == "" {
-		return "/" + key
+// decodeDocuments decodes the original and modified json documents a and b,
+// see decodeDocument.
+func decodeDocuments(a, b []byte, useNumber bool) (interface{}, interface{}, error) {
+	aI, err := decodeDocument(a, "original", useNumber)
+	if err != nil {
+		return nil, nil, err
 	}
-	if strings.HasSuffix(path, "/") {
-		return path + key
+	bI, err := decodeDocument(b, "modified", useNumber)
+	if err != nil {
+		return nil, nil, err
 	}
-	return path + "/" + key
+	return aI, bI, nil
 }
 
-func diff(a, b interface{}, p string, patch []JSONPatchOperation) ([]JSONPatchOperation, error) {
-	// If values are not of the same type simply replace
-	if reflect.TypeOf(a) != reflect.TypeOf(b) {
-		patch = append(patch, NewPatch("replace", p, b))
+// makePath returns the JSON Pointer to newPart below path, escaping newPart
+// so that the pointer always parses back into the same reference tokens.
+func makePath(path string, newPart interface{}) string {
+	return path + pointer.Format(fmt.Sprintf("%v", newPart))
+}
+
+func (d *differ) diff(a, b interface{}, p string, patch []JSONPatchOperation) ([]JSONPatchOperation, error) {
+	if d.isIgnored(p) {
 		return patch, nil
 	}
+	ak, bk := kindOf(a), kindOf(b)
+	if ak == kindUnsupported {
+		return nil, &UnsupportedTypeError{Path: p, Value: a}
+	}
+	if bk == kindUnsupported {
+		return nil, &UnsupportedTypeError{Path: p, Value: b}
+	}
+	if d.equalByEqualer(a, b, p) {
+		d.trace("value", TraceKeep, p, "equal according to its Equaler")
+		return patch, nil
+	}
+	if d.isPartial(p) && (ak != bk || (ak != kindObject && ak != kindArray)) {
+		return patch, nil
+	}
+	// If values are not of the same type simply replace
+	if ak != bk {
+		d.trace("value", TraceReplace, p, "type changed from %T to %T", a, b)
+		return d.replace(patch, p, a, b), nil
+	}
 
-	var err error
-	var patch2 []JSONPatchOperation
-	switch at := a.(type) {
-	case map[string]interface{}:
-		bt := b.(map[string]interface{})
-		patch2, err = diffObjects(at, bt, p)
+	switch ak {
+	case kindObject:
+		patch2, err := d.diffObjects(a.(map[string]interface{}), b.(map[string]interface{}), p)
 		if err != nil {
 			return nil, err
 		}
 		patch = append(patch, patch2...)
-	case string, float64, bool:
-		if !reflect.DeepEqual(a, b) {
-			patch = append(patch, NewPatch("replace", p, b))
-		}
-	case []interface{}:
-		bt, ok := b.([]interface{})
-		if !ok {
-			// array replaced by non-array
-			patch = append(patch, NewPatch("replace", p, b))
-		} else {
-			// arrays are not the same length
-			patch2, err = diffArrays(at, bt, p, false)
-			if err != nil {
-				return nil, err
-			}
-			patch = append(patch, patch2...)
-		}
-	case nil:
-		switch b.(type) {
-		case nil:
-			// Both nil, fine.
-		default:
-			patch = append(patch, NewPatch("add", p, b))
+	case kindArray:
+		patch2, err := d.diffArrays(a.([]interface{}), b.([]interface{}), p, false)
+		if err != nil {
+			return nil, err
 		}
+		patch = append(patch, patch2...)
+	case kindNull:
+		// Both nil, fine.
 	default:
-		panic(fmt.Sprintf("Unknown type:%T ", a))
+		if !d.equal(a, b) {
+			d.trace("value", TraceReplace, p, "%v changed to %v", a, b)
+			patch = d.replace(patch, p, a, b)
+		}
 	}
 	return patch, nil
 }
 
-// diff returns the (recursive) difference between a and b as an array of JsonPatchOperations.
-func diffObjects(a, b map[string]interface{}, path string) ([]JSONPatchOperation, error) {
-	fullReplace := []JSONPatchOperation{NewPatch("replace", path, b)}
+// diffObjects returns the operations turning object a at path into b, diffing
+// members present in both recursively. Replacing the whole object is returned
+// instead when that is cheaper, see WithReplaceThreshold.
+//
+// The operations come in a fixed order, so the same documents always produce
+// the same patch: first the removals of members missing from b, by sorted
+// key, then, again by sorted key, the members of b that were renamed, added
+// or changed.
+func (d *differ) diffObjects(a, b map[string]interface{}, path string) ([]JSONPatchOperation, error) {
+	fullReplace := d.replace(nil, path, a, b)
 	patch := []JSONPatchOperation{}
-	for key, bv := range b {
+
+	var removed, added []string
+	for _, key := range sortedKeys(a) {
+		if _, ok := b[key]; !ok && !d.isIgnored(makePath(path, key)) && !d.isPartial(makePath(path, key)) {
+			removed = append(removed, key)
+		}
+	}
+	for _, key := range sortedKeys(b) {
+		if _, ok := a[key]; !ok && !d.isIgnored(makePath(path, key)) && !d.isPartial(makePath(path, key)) {
+			added = append(added, key)
+		}
+	}
+	// Members whose value reappears under a new name are moved there
+	renamed := map[string]string{}
+	for _, key := range removed {
+		for _, newKey := range added {
+			if _, ok := renamed[newKey]; !ok && reflect.DeepEqual(a[key], b[newKey]) {
+				d.trace("object", TraceMove, makePath(path, newKey), "member %q renamed", key)
+				renamed[newKey] = key
+				break
+			}
+		}
+	}
+	for _, key := range removed {
+		if !containsValue(renamed, key) {
+			d.trace("object", TraceRemove, makePath(path, key), "member removed")
+			patch = d.remove(patch, makePath(path, key), a[key])
+		}
+	}
+
+	for _, key := range sortedKeys(b) {
+		bv := b[key]
 		p := makePath(path, key)
+		if d.isIgnored(p) {
+			continue
+		}
 		av, ok := a[key]
+		if d.isPartial(p) && (!ok || kindOf(av) != kindOf(bv)) {
+			continue
+		}
 		// Key doesn't exist in original document, value was added
 		if !ok {
-			patch = append(patch, NewPatch("add", p, bv))
+			if from, ok := renamed[key]; ok {
+				patch = append(patch, JSONPatchOperation{Operation: "move", Path: p, From: makePath(path, from)})
+				continue
+			}
+			d.trace("object", TraceAdd, p, "member added")
+			patch = d.add(patch, p, bv)
 			continue
 		}
 		// If types have changed, replace completely
-		if reflect.TypeOf(av) != reflect.TypeOf(bv) {
-			patch = append(patch, NewPatch("replace", p, bv))
+		if kindOf(av) != kindOf(bv) && !d.equalByEqualer(av, bv, p) {
+			d.trace("object", TraceReplace, p, "type changed from %T to %T", av, bv)
+			patch = d.replace(patch, p, av, bv)
 			continue
 		}
 		// Types are the same, compare values
 		var err error
-		patch, err = diff(av, bv, p, patch)
+		patch, err = d.diff(av, bv, p, patch)
 		if err != nil {
 			return nil, err
 		}
 	}
-	// Now add all deleted values as nil
-	for key := range a {
-		_, ok := b[key]
+	return d.smallest("object", path, fullReplace, patch), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsValue(m map[string]string, v string) bool {
+	for _, e := range m {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends the addition of value at path to patch.
+func (d *differ) add(patch []JSONPatchOperation, path string, value interface{}) []JSONPatchOperation {
+	return append(patch, NewPatch("add", path, d.withoutExcluded(value, path)))
+}
+
+// replace appends the replacement of old by value at path to patch.
+func (d *differ) replace(patch []JSONPatchOperation, path string, old, value interface{}) []JSONPatchOperation {
+	if d.testOps {
+		patch = append(patch, NewPatch("test", path, old))
+	}
+	op := NewPatch("replace", path, d.withoutExcluded(value, path))
+	if d.oldValues {
+		op.OldValue = old
+		op.HasOldValue = true
+	}
+	return append(patch, op)
+}
+
+// remove appends the removal of old at path to patch.
+func (d *differ) remove(patch []JSONPatchOperation, path string, old interface{}) []JSONPatchOperation {
+	if d.testOps {
+		patch = append(patch, NewPatch("test", path, old))
+	}
+	op := NewPatch("remove", path, nil)
+	if d.oldValues {
+		op.OldValue = old
+		op.HasOldValue = true
+	}
+	return append(patch, op)
+}
+
+// smallest returns fullReplace, the replacement of the value at path, instead
+// of patch if that is allowed and cheap enough, see WithReplaceThreshold.
+// differ names the caller for tracing.
+func (d *differ) smallest(differ, path string, fullReplace, patch []JSONPatchOperation) []JSONPatchOperation {
+	if !d.minimise || d.ignoresBelow(path) {
+		return patch
+	}
+	fullCost, patchCost := d.cost(fullReplace), d.cost(patch)
+	if patchCost > d.replaceThreshold*fullCost || patchCost == d.replaceThreshold*fullCost && d.costTiesReplace {
+		d.trace(differ, TraceFullReplace, path, "replace costs %g, %d operations cost %g", fullCost, len(patch), patchCost)
+		return fullReplace
+	}
+	return patch
+}
+
+// useCopies turns add operations into copy operations where the added value
+// can be found unchanged elsewhere in the original document and copying it is
+// shorter than spelling it out.
+func useCopies(a, b interface{}, patch []JSONPatchOperation) []JSONPatchOperation {
+	sources := map[string]string{}
+	collectUnchanged(a, b, "", sources)
+	if len(sources) == 0 {
+		return patch
+	}
+	for i, op := range patch {
+		if op.Operation != "add" {
+			continue
+		}
+		v, err := json.Marshal(op.Value)
+		if err != nil {
+			continue
+		}
+		from, ok := sources[string(v)]
 		if !ok {
-			p := makePath(path, key)
-			patch = append(patch, NewPatch("remove", p, nil))
+			continue
+		}
+		cp := JSONPatchOperation{Operation: "copy", Path: op.Path, From: from}
+		if len(cp.JSON()) < len(op.JSON()) {
+			patch[i] = cp
 		}
 	}
-	return getSmallestPatch(fullReplace, patch), nil
+	return patch
 }
 
-func getSmallestPatch(patches ...[]JSONPatchOperation) []JSONPatchOperation {
-	smallestPatch := patches[0]
-	b, _ := json.Marshal(patches[0])
-	smallestSize := len(b)
-	for i := 1; i < len(patches); i++ {
-		p := patches[i]
-		b, _ := json.Marshal(p)
-		size := len(b)
-		if size < smallestSize {
-			smallestPatch = p
-			smallestSize = size
+// collectUnchanged records the json encoding of every container or string
+// that is equal in a and b at the same object path. Paths through arrays are
+// left out as indices shift while a patch is applied, object members that are
+// equal in both documents are never touched by the patch.
+func collectUnchanged(a, b interface{}, path string, sources map[string]string) {
+	at, ok := a.(map[string]interface{})
+	if !ok {
+		return
+	}
+	bt, ok := b.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range sortedKeys(at) {
+		av := at[key]
+		bv, ok := bt[key]
+		if !ok {
+			continue
 		}
+		p := makePath(path, key)
+		if reflect.DeepEqual(av, bv) {
+			switch av.(type) {
+			case map[string]interface{}, []interface{}, string:
+				v, err := json.Marshal(av)
+				if err == nil {
+					if _, ok := sources[string(v)]; !ok {
+						sources[string(v)] = p
+					}
+				}
+			}
+		}
+		collectUnchanged(av, bv, p, sources)
 	}
-	return smallestPatch
+}
+
+// patchSize returns the length of patch encoded as json.
+func patchSize(patch []JSONPatchOperation) int {
+	b, _ := json.Marshal(patch)
+	return len(b)
 }
@@ -0,0 +1,104 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type applyTest struct {
+	Comment  string          `json:"comment"`
+	Doc      json.RawMessage `json:"doc"`
+	Patch    json.RawMessage `json:"patch"`
+	Expected json.RawMessage `json:"expected"`
+	Disabled bool            `json:"disabled"`
+	Error    *string         `json:"error"`
+}
+
+func TestApply(t *testing.T) {
+	file, err := ioutil.ReadFile("tests.json")
+	assert.NoError(t, err)
+
+	var applyTests []applyTest
+	err = json.Unmarshal(file, &applyTests)
+	assert.NoError(t, err)
+
+	for i, tc := range applyTests {
+		testName := fmt.Sprintf(`Test #%d %s`, i, tc.Comment)
+		t.Run(testName, func(t *testing.T) {
+			patch, err := DecodePatch(tc.Patch)
+			if err == nil {
+				var modified []byte
+				modified, err = Apply(tc.Doc, patch)
+				if tc.Error == nil {
+					assert.NoError(t, err)
+					// cases without an expected document only need to apply
+					if tc.Expected != nil {
+						assert.JSONEq(t, string(tc.Expected), string(modified))
+					}
+					return
+				}
+			}
+			if tc.Error != nil {
+				assert.Error(t, err, *tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyCreatedPatch(t *testing.T) {
+	cases := map[string]struct {
+		a string
+		b string
+	}{
+		"simple":       {simpleA, simpleB},
+		"vs empty":     {simpleA, empty},
+		"complex":      {complexBase, complexD},
+		"super":        {superComplexBase, superComplexA},
+		"point":        {point, lineString},
+		"array":        {arrayWithSpacesBase, arrayWithSpacesUpdated},
+		"root array":   {`[1, 2, 3]`, `[0, 2, 3, 4]`},
+		"to scalar":    {`{"a":[1]}`, `"a"`},
+		"escaped keys": {`{"a/b":1, "c~d":2}`, `{"a/b":3, "e":{"c~d":2}}`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			patch, err := CreatePatch([]byte(tc.a), []byte(tc.b))
+			assert.NoError(t, err)
+
+			modified, err := Apply([]byte(tc.a), patch)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.b, string(modified))
+		})
+	}
+}
+
+func TestApplyMoveAndCopy(t *testing.T) {
+	doc := []byte(`{"a":{"b":[1, 2]}, "c":"d"}`)
+	patch := []JSONPatchOperation{
+		{Operation: "copy", From: "/a/b", Path: "/e"},
+		{Operation: "move", From: "/c", Path: "/a/b/0"},
+		{Operation: "add", Path: "/e/-", Value: 3},
+	}
+	modified, err := Apply(doc, patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":{"b":["d", 1, 2]}, "e":[1, 2, 3]}`, string(modified))
+
+	_, err = Apply(doc, []JSONPatchOperation{{Operation: "move", From: "/a", Path: "/a/b/0"}})
+	assert.Error(t, err)
+}
+
+func TestApplyInvalidDocument(t *testing.T) {
+	_, err := Apply([]byte(`{"a":`), nil)
+	var docErr *InvalidDocumentError
+	assert.True(t, errors.As(err, &docErr))
+	assert.Equal(t, "original", docErr.Which)
+	assert.Equal(t, int64(5), docErr.Offset)
+}
@@ -0,0 +1,205 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type applyToItem struct {
+	SKU      string  `json:"sku"`
+	Quantity int8    `json:"qty"`
+	Price    float64 `json:"price,omitempty"`
+}
+
+type applyToOrder struct {
+	ID       uint64                 `json:"id,string"`
+	Customer *valuesAddress         `json:"customer"`
+	Items    []applyToItem          `json:"items"`
+	Tags     []string               `json:"tags"`
+	Labels   map[string]string      `json:"labels"`
+	Counts   map[int]int            `json:"counts"`
+	Meta     map[string]interface{} `json:"meta"`
+	Shipped  *time.Time             `json:"shipped,omitempty"`
+	Pair     [2]string              `json:"pair"`
+	Notes    interface{}            `json:"notes"`
+	Secret   string                 `json:"-"`
+}
+
+func newApplyToOrder() applyToOrder {
+	return applyToOrder{
+		ID:       7,
+		Customer: &valuesAddress{Street: "Main"},
+		Items:    []applyToItem{{SKU: "a", Quantity: 1}, {SKU: "b", Quantity: 2, Price: 1.5}},
+		Tags:     []string{"x"},
+		Labels:   map[string]string{"env": "prod", "team": "core"},
+		Counts:   map[int]int{1: 10},
+		Meta:     map[string]interface{}{"nested": map[string]interface{}{"list": []interface{}{1.0, 2.0}}},
+		Pair:     [2]string{"l", "r"},
+		Notes:    []interface{}{"first"},
+		Secret:   "s",
+	}
+}
+
+func TestApplyTo(t *testing.T) {
+	patch := []JSONPatchOperation{
+		{Operation: "test", Path: "/id", Value: "7"},
+		{Operation: "replace", Path: "/id", Value: "8"},
+		{Operation: "add", Path: "/customer/city", Value: "Springfield"},
+		{Operation: "add", Path: "/items/1", Value: map[string]interface{}{"sku": "c", "qty": 3}},
+		{Operation: "replace", Path: "/items/0/qty", Value: json.Number("5")},
+		{Operation: "remove", Path: "/items/2"},
+		{Operation: "add", Path: "/items/-", Value: applyToItem{SKU: "d", Price: 2}},
+		{Operation: "add", Path: "/tags/0", Value: "w"},
+		{Operation: "remove", Path: "/labels/team"},
+		{Operation: "add", Path: "/labels/owner", Value: "me"},
+		{Operation: "add", Path: "/counts/2", Value: 20},
+		{Operation: "add", Path: "/meta/nested/list/1", Value: 1.5},
+		{Operation: "move", From: "/meta/nested", Path: "/meta/moved"},
+		{Operation: "add", Path: "/shipped", Value: "2021-02-03T04:05:06Z"},
+		{Operation: "replace", Path: "/pair/1", Value: "R"},
+		{Operation: "add", Path: "/notes/-", Value: map[string]interface{}{"n": 1}},
+		{Operation: "copy", From: "/items/0", Path: "/items/-"},
+		{Operation: "test", Path: "/items/3", Value: map[string]interface{}{"sku": "a", "qty": 5}},
+	}
+
+	order := newApplyToOrder()
+	assert.NoError(t, ApplyTo(&order, patch))
+
+	original, err := json.Marshal(newApplyToOrder())
+	assert.NoError(t, err)
+	modified, err := Apply(original, patch)
+	assert.NoError(t, err)
+	var expected applyToOrder
+	assert.NoError(t, json.Unmarshal(modified, &expected))
+	expected.Secret = "s"
+	assert.Equal(t, expected, order)
+
+	shipped := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	assert.True(t, shipped.Equal(*order.Shipped))
+	assert.Equal(t, uint64(8), order.ID)
+	assert.Equal(t, []string{"w", "x"}, order.Tags)
+	assert.Equal(t, map[int]int{1: 10, 2: 20}, order.Counts)
+	assert.Equal(t, []interface{}{"first", map[string]interface{}{"n": float64(1)}}, order.Notes)
+}
+
+func TestApplyToCreatedPatch(t *testing.T) {
+	a := newApplyToOrder()
+	b := newApplyToOrder()
+	b.Items = append(b.Items[1:], applyToItem{SKU: "z", Quantity: 9})
+	b.Labels["env"] = "dev"
+	b.Customer = nil
+	b.Meta["nested"] = "flat"
+
+	patch, err := CreatePatchFromValues(a, b)
+	assert.NoError(t, err)
+	assert.NoError(t, ApplyTo(&a, patch))
+	assert.Equal(t, b, a)
+}
+
+type applyToPositions struct {
+	Pos   [4]string         `json:"pos"`
+	Pair  [2]int            `json:"pair"`
+	Grid  [2][2]int         `json:"grid"`
+	ByKey map[string][3]int `json:"byKey"`
+}
+
+func newApplyToPositions() applyToPositions {
+	long := strings.Repeat("position ", 5)
+	return applyToPositions{
+		Pos:   [4]string{long + "a", long + "b", long + "c", long + "d"},
+		Pair:  [2]int{1, 2},
+		Grid:  [2][2]int{{1, 2}, {3, 4}},
+		ByKey: map[string][3]int{"k": {1, 2, 3}},
+	}
+}
+
+func TestApplyToFixedArrays(t *testing.T) {
+	cases := []func(p *applyToPositions){
+		func(p *applyToPositions) { p.Pos[3] = p.Pos[3] + "!" },
+		func(p *applyToPositions) { p.Pos[0], p.Pos[2] = p.Pos[2], p.Pos[0] },
+		func(p *applyToPositions) { p.Pos = [4]string{p.Pos[3], p.Pos[0], p.Pos[1], p.Pos[2]} },
+		func(p *applyToPositions) { p.Pair = [2]int{2, 5} },
+		func(p *applyToPositions) { p.Grid = [2][2]int{{3, 4}, {2, 1}} },
+		func(p *applyToPositions) { p.ByKey["k"] = [3]int{3, 1, 2} },
+	}
+	for _, change := range cases {
+		a, b := newApplyToPositions(), newApplyToPositions()
+		change(&b)
+		patch, err := CreatePatchFromValues(a, b)
+		assert.NoError(t, err)
+		assert.NoError(t, ApplyTo(&a, patch), "%v", patch)
+		assert.Equal(t, b, a, "%v", patch)
+	}
+
+	// the length is checked once the operations on the elements are done
+	p := newApplyToPositions()
+	err := ApplyTo(&p, []JSONPatchOperation{
+		NewPatch("replace", "/pair/0", 7),
+		NewPatch("remove", "/pair/1", nil),
+		NewPatch("replace", "/grid/0/0", 0),
+	})
+	var opErr *InvalidOperationError
+	if assert.True(t, errors.As(err, &opErr), "%v", err) {
+		assert.Equal(t, 1, opErr.Index)
+	}
+	assert.Equal(t, [2]int{1, 2}, p.Pair)
+}
+
+func TestApplyToMapAndSlice(t *testing.T) {
+	m := map[string]interface{}{"a": []interface{}{1.0}}
+	assert.NoError(t, ApplyTo(&m, []JSONPatchOperation{NewPatch("add", "/a/-", 2), NewPatch("add", "/b", true)}))
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{1.0, 2.0}, "b": true}, m)
+
+	s := []int{1, 2, 3}
+	assert.NoError(t, ApplyTo(&s, []JSONPatchOperation{NewPatch("remove", "/0", nil), {Operation: "move", From: "/1", Path: "/0"}}))
+	assert.Equal(t, []int{3, 2}, s)
+
+	assert.NoError(t, ApplyTo(&s, []JSONPatchOperation{NewPatch("replace", "", []int{4})}))
+	assert.Equal(t, []int{4}, s)
+}
+
+func TestApplyToErrors(t *testing.T) {
+	cases := []struct {
+		op       JSONPatchOperation
+		errType  interface{}
+		mismatch bool
+	}{
+		{NewPatch("replace", "/items/0/qty", "many"), &InvalidOperationError{}, true},
+		{NewPatch("replace", "/items/0/qty", 300), &InvalidOperationError{}, true},
+		{NewPatch("replace", "/items/0/qty", 1.5), &InvalidOperationError{}, true},
+		{NewPatch("replace", "/tags", map[string]interface{}{}), &InvalidOperationError{}, true},
+		{NewPatch("add", "/counts/x", 1), &InvalidOperationError{}, true},
+		{NewPatch("add", "/shipped", "yesterday"), &InvalidOperationError{}, true},
+		{NewPatch("add", "/pair/0", "x"), &InvalidOperationError{}, false},
+		{NewPatch("replace", "/unknown", 1), &PathNotFoundError{}, false},
+		{NewPatch("remove", "/labels/missing", nil), &PathNotFoundError{}, false},
+		{NewPatch("replace", "/items/5/qty", 1), &PathNotFoundError{}, false},
+		{NewPatch("test", "/tags/0", "y"), &TestFailedError{}, false},
+	}
+	for _, tc := range cases {
+		order := newApplyToOrder()
+		err := ApplyTo(&order, []JSONPatchOperation{tc.op})
+		if !assert.Error(t, err, "%v", tc.op) {
+			continue
+		}
+		assert.IsType(t, tc.errType, err, "%v", err)
+		var mismatch *TypeMismatchError
+		assert.Equal(t, tc.mismatch, errors.As(err, &mismatch), "%v", err)
+		if tc.mismatch {
+			assert.Equal(t, tc.op.Path, mismatch.Path)
+			assert.NotNil(t, mismatch.Type)
+		}
+	}
+
+	order := newApplyToOrder()
+	assert.Error(t, ApplyTo(order, nil))
+	var nilOrder *applyToOrder
+	assert.Error(t, ApplyTo(nilOrder, nil))
+	assert.True(t, reflect.DeepEqual(newApplyToOrder(), order))
+}
@@ -56,7 +56,6 @@ var (
 
 // TestArrayRemoveSpaceInbetween tests removing one blank item from a group blanks which is in between non blank items which also end with a blank item. This tests that the correct index is removed
 func TestArrayRemoveSpaceInbetween(t *testing.T) {
-	t.Skip("This test fails. TODO change compareArray algorithm to match by index instead of by object equality")
 	patch, e := CreatePatch([]byte(arrayWithSpacesBase), []byte(arrayWithSpacesUpdated))
 	assert.NoError(t, e)
 	t.Log("Patch:", patch)
@@ -1,8 +1,10 @@
 package jsonpatch
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/herkyl/jsonpatch/pointer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,3 +33,18 @@ func TestReplaceScalars(t *testing.T) {
 	assert.Equal(t, "", p.Path)
 	assert.Equal(t, "s", p.Value)
 }
+
+func TestPathsRoundTrip(t *testing.T) {
+	a := fmt.Sprintf(`{"a/b":{"~c":[1], "l":"%s"}, "":{"":1, "l":"%s"}}`, lorem, lorem)
+	b := fmt.Sprintf(`{"a/b":{"~c":[1, 2], "l":"%s"}, "":{"":2, "l":"%s"}}`, lorem, lorem)
+	patch, e := CreatePatch([]byte(a), []byte(b))
+	assert.NoError(t, e)
+	paths := []string{}
+	for _, p := range patch {
+		ptr, err := pointer.Parse(p.Path)
+		assert.NoError(t, err)
+		assert.Equal(t, p.Path, ptr.String())
+		paths = append(paths, p.Path)
+	}
+	assert.ElementsMatch(t, []string{"/a~1b/~0c/1", "//"}, paths)
+}
@@ -0,0 +1,109 @@
+package jsonpatch
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostFuncs(t *testing.T) {
+	patch := []JSONPatchOperation{
+		NewPatch("replace", "/a/b/c", 1),
+		NewPatch("remove", "/d", nil),
+		NewPatch("add", "", []interface{}{}),
+	}
+	assert.Equal(t, float64(patchSize(patch)), CostBytes(patch))
+	assert.Equal(t, 3.0, CostOperations(patch))
+	assert.Equal(t, 1.125+1.5+2.0, CostDepthWeighted(patch))
+	assert.Equal(t, 0.0, CostOperations(nil))
+}
+
+func TestReplaceThreshold(t *testing.T) {
+	a, b := `{"a":[1, 2, 3]}`, `{"a":[1, 0, 0]}`
+	cases := []struct {
+		name    string
+		opts    []Option
+		n       int
+		replace string
+	}{
+		{"default", nil, 1, "/a"},
+		// a replace of the whole document is a single operation too, ties
+		// keep the finer patch
+		{"operations", []Option{WithCostFunc(CostOperations)}, 1, "/a"},
+		{"bytes above threshold", []Option{WithReplaceThreshold(3)}, 4, ""},
+		{"operations above threshold", []Option{WithCostFunc(CostOperations), WithReplaceThreshold(4.5)}, 4, ""},
+		{"operations at threshold", []Option{WithCostFunc(CostOperations), WithReplaceThreshold(4)}, 4, ""},
+		{"operations within threshold", []Option{WithCostFunc(CostOperations), WithReplaceThreshold(3.5)}, 1, "/a"},
+		{"nil restores default", []Option{WithCostFunc(CostOperations), WithCostFunc(nil), WithReplaceThreshold(3)}, 4, ""},
+	}
+	for _, tc := range cases {
+		patch, err := CreatePatchWithOptions([]byte(a), []byte(b), tc.opts...)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.n, len(patch), "%s: %v", tc.name, patch)
+		if tc.n == 1 {
+			assert.Equal(t, tc.replace, patch[0].Path, tc.name)
+		}
+		modified, err := Apply([]byte(a), patch)
+		assert.NoError(t, err, tc.name)
+		assert.JSONEq(t, b, string(modified), tc.name)
+	}
+
+	patch, err := CreatePatchWithOptions([]byte(simpleA), []byte(simpleB), WithReplaceThreshold(0))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "", map[string]interface{}{"a": 100.0, "b": 200.0, "c": "goodbye"})}, patch)
+
+	for _, threshold := range []float64{-1, math.NaN()} {
+		_, err = CreatePatchWithOptions([]byte(simpleA), []byte(simpleB), WithReplaceThreshold(threshold))
+		assert.Error(t, err)
+	}
+}
+
+func TestDepthWeightedCost(t *testing.T) {
+	a := `{"a":{"b":{"c":1, "d":2}}, "e":1}`
+	b := `{"a":{"b":{"c":3, "d":4}}, "e":1}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithCostFunc(CostDepthWeighted))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "/a/b", map[string]interface{}{"c": 3.0, "d": 4.0})}, patch)
+}
+
+func TestCostFuncsKeepSingleLeafChange(t *testing.T) {
+	cases := []struct {
+		a, b, path string
+	}{
+		{`{"a":1}`, `{"a":2}`, "/a"},
+		{`{"a":{"b":{"c":1}}}`, `{"a":{"b":{"c":2}}}`, "/a/b/c"},
+		{`[{"a":{"b":"x", "c":"y"}}]`, `[{"a":{"b":"z", "c":"y"}}]`, "/0/a/b"},
+	}
+	for name, cost := range map[string]CostFunc{"bytes": CostBytes, "operations": CostOperations, "depth": CostDepthWeighted} {
+		for _, tc := range cases {
+			patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithCostFunc(cost))
+			assert.NoError(t, err)
+			if assert.Equal(t, 1, len(patch), "%s: %s -> %s: %v", name, tc.a, tc.b, patch) {
+				assert.Equal(t, "replace", patch[0].Operation, name)
+				assert.Equal(t, tc.path, patch[0].Path, name)
+			}
+		}
+	}
+}
+
+func TestCustomCostFunc(t *testing.T) {
+	// replacing whole objects is never worth it, so every change is kept
+	// for auditing
+	cost := func(patch []JSONPatchOperation) float64 {
+		for _, op := range patch {
+			if _, ok := op.Value.(map[string]interface{}); ok && op.Operation == "replace" {
+				return math.Inf(1)
+			}
+		}
+		return CostOperations(patch)
+	}
+	a := `{"user":{"name":"a", "email":"a@example.com", "roles":["x"]}}`
+	b := `{"user":{"name":"b", "email":"b@example.com", "roles":["y"]}}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithCostFunc(cost))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/user/name", "/user/email", "/user/roles"}, Patch(patch).Paths())
+	modified, err := Apply([]byte(a), patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, b, string(modified))
+}
@@ -0,0 +1,137 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalEscaping(t *testing.T) {
+	a := `{"a\"b":1, "c\\d":{"e\u0001/~":2}, "f\n":[3]}`
+	b := `{"a\"b":2, "c\\d":{"e\u0001/~":3}, "f\n":[3, 4]}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithMinimisation(false))
+	assert.NoError(t, err)
+
+	encoded, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.True(t, json.Valid(encoded), string(encoded))
+	assert.Contains(t, string(encoded), `"path":"/a\"b"`)
+	assert.Contains(t, string(encoded), `"path":"/c\\d/e\u0001~1~0"`)
+
+	decoded, err := DecodePatch(encoded)
+	assert.NoError(t, err)
+	modified, err := decoded.Apply([]byte(a))
+	assert.NoError(t, err)
+	assert.JSONEq(t, b, string(modified))
+
+	op := JSONPatchOperation{Operation: "move", From: `/"`, Path: "/\t"}
+	assert.Equal(t, `{"op":"move","path":"/\t","from":"/\""}`, op.JSON())
+}
+
+func TestEncoder(t *testing.T) {
+	patch := Patch{
+		NewPatch("add", "/a", []interface{}{1, "x"}),
+		{Operation: "copy", From: "/a", Path: "/b\""},
+		NewPatch("remove", "/c", nil),
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	assert.NoError(t, enc.Encode(patch))
+	assert.NoError(t, enc.Encode(nil))
+	marshalled, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, string(marshalled)+"\n[]\n", buf.String())
+
+	buf.Reset()
+	err = enc.Encode([]JSONPatchOperation{NewPatch("remove", "/a", nil), NewPatch("add", "/b", math.NaN())})
+	assert.Error(t, err)
+	assert.Equal(t, `[{"op":"remove","path":"/a"}`, buf.String())
+
+	writeErr := errors.New("closed")
+	err = NewEncoder(failingWriter{writeErr}).Encode(patch)
+	assert.Equal(t, writeErr, err)
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+// FuzzOperationEncoding checks that every operation is encoded as valid json
+// holding the same members.
+func FuzzOperationEncoding(f *testing.F) {
+	f.Add("add", "/a", "", "b")
+	f.Add("move", "/a\"b", "/c\\d", "")
+	f.Add("spam", "/\x00\n ", "\x7f", "</script>")
+	f.Fuzz(func(t *testing.T, operation, path, from, value string) {
+		for _, s := range []string{operation, path, from, value} {
+			if !utf8.ValidString(s) {
+				t.Skip("json cannot hold invalid UTF-8")
+			}
+		}
+		op := JSONPatchOperation{Operation: operation, Path: path, From: from, Value: value}
+		b, err := json.Marshal(&op)
+		if !assert.NoError(t, err) || !assert.True(t, json.Valid(b), "%q", b) {
+			return
+		}
+		var members map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b, &members))
+		assert.Equal(t, operation, members["op"])
+		assert.Equal(t, path, members["path"])
+		assert.Equal(t, value, members["value"])
+		if from != "" || operation == "move" || operation == "copy" {
+			assert.Equal(t, from, members["from"])
+		} else {
+			assert.NotContains(t, members, "from")
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, NewEncoder(&buf).Encode([]JSONPatchOperation{op}))
+		assert.Equal(t, "["+string(b)+"]\n", buf.String())
+	})
+}
+
+// FuzzPatchEncoding checks that patches created between documents with
+// arbitrary keys encode to documents that decode back to the same operations.
+func FuzzPatchEncoding(f *testing.F) {
+	f.Add("a", "b", "c")
+	f.Add("a\"b", "c\\d", "e\nf")
+	f.Add("~1/", "", "\u0000")
+	f.Fuzz(func(t *testing.T, keyA, keyB, value string) {
+		a, err := json.Marshal(map[string]interface{}{keyA: 1, "k": map[string]interface{}{keyB: value}})
+		assert.NoError(t, err)
+		b, err := json.Marshal(map[string]interface{}{keyB: value, "k": map[string]interface{}{keyA: []interface{}{value}}})
+		assert.NoError(t, err)
+		patch, err := CreatePatchWithOptions(a, b, WithMinimisation(false))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, NewEncoder(&buf).Encode(patch))
+		if !assert.True(t, json.Valid(buf.Bytes()), buf.String()) {
+			return
+		}
+		decoded, err := DecodePatch(buf.Bytes())
+		if !assert.NoError(t, err) || !assert.Equal(t, len(patch), decoded.Len()) {
+			return
+		}
+		for i, op := range decoded {
+			assert.Equal(t, patch[i].Operation, op.Operation)
+			assert.Equal(t, patch[i].Path, op.Path)
+			assert.Equal(t, patch[i].From, op.From)
+			assert.True(t, (&options{}).equal(patch[i].Value, op.Value), "%v", op)
+		}
+		modified, err := decoded.Apply(a)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(b), string(modified))
+	})
+}
@@ -0,0 +1,106 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	evanphx "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+)
+
+// applyWithEvanphx applies patch to doc with another RFC 6902 implementation.
+func applyWithEvanphx(t *testing.T, doc []byte, patch []JSONPatchOperation) ([]byte, error) {
+	pb, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	ep, err := evanphx.DecodePatch(pb)
+	if err != nil {
+		return nil, err
+	}
+	return ep.Apply(doc)
+}
+
+func TestEndOfArrayAdds(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		paths []string
+	}{
+		{`{"a":[1,2,3]}`, `{"a":[1,2,3,4,5]}`, []string{"/a/-", "/a/-"}},
+		{`{"a":[]}`, `{"a":[1]}`, []string{"/a/-"}},
+		{`{"a":[1,2,3]}`, `{"a":[0,2,3,4]}`, []string{"/a/0", "/a/0", "/a/-"}},
+		{`{"a":[1,2,3]}`, `{"a":[3,4]}`, []string{"/a/0", "/a/0", "/a/-"}},
+		{`{"a":{"list":[[1]]}}`, `{"a":{"list":[[1,2],[3]]}}`, []string{"/a/list/0/-", "/a/list/-"}},
+		{`{"a":[1,2]}`, `{"a":[1,5,2]}`, []string{"/a/1"}},
+	}
+	for _, tc := range cases {
+		patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithEndOfArrayAdds(), WithMinimisation(false))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.paths, Patch(patch).Paths(), "%s -> %s: %v", tc.a, tc.b, patch)
+
+		modified, err := applyWithEvanphx(t, []byte(tc.a), patch)
+		assert.NoError(t, err)
+		assert.JSONEq(t, tc.b, string(modified))
+		modified, err = Apply([]byte(tc.a), patch)
+		assert.NoError(t, err)
+		assert.JSONEq(t, tc.b, string(modified))
+	}
+}
+
+func TestEndOfArrayAddsWithKeys(t *testing.T) {
+	a := `{"items":[{"id":1,"v":"a"},{"id":2,"v":"b"}]}`
+	b := `{"items":[{"id":2,"v":"b"},{"id":1,"v":"c"},{"id":3,"v":"d"}]}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithArrayKey("/items", "id"), WithEndOfArrayAdds(), WithMinimisation(false))
+	assert.NoError(t, err)
+	assert.Contains(t, Patch(patch).Paths(), "/items/-")
+	modified, err := applyWithEvanphx(t, []byte(a), patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, b, string(modified))
+}
+
+// TestEndOfArrayAddsConcurrently applies a patch to a document whose array
+// grew since the patch was created.
+func TestEndOfArrayAddsConcurrently(t *testing.T) {
+	a, b := `{"log":["x","y"]}`, `{"log":["x","y","z"]}`
+	grown := `{"log":["x","y","w"]}`
+
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithMinimisation(false))
+	assert.NoError(t, err)
+	modified, err := applyWithEvanphx(t, []byte(grown), patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"log":["x","y","z","w"]}`, string(modified))
+
+	patch, err = CreatePatchWithOptions([]byte(a), []byte(b), WithEndOfArrayAdds(), WithMinimisation(false))
+	assert.NoError(t, err)
+	modified, err = applyWithEvanphx(t, []byte(grown), patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"log":["x","y","w","z"]}`, string(modified))
+}
+
+func TestEndOfArrayAddsRandomDocuments(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	tails := 0
+	for n := 0; n < 500; n++ {
+		// evanphx/json-patch cannot replace the root, wrap the documents
+		a, _ := json.Marshal(RootWrap{randomValue(r, 3)})
+		b, _ := json.Marshal(RootWrap{randomValue(r, 3)})
+		for _, opts := range [][]Option{
+			{WithEndOfArrayAdds()},
+			{WithEndOfArrayAdds(), WithMinimisation(false)},
+		} {
+			patch, err := CreatePatchWithOptions(a, b, opts...)
+			assert.NoError(t, err)
+			for _, op := range patch {
+				if strings.HasSuffix(op.Path, "/-") {
+					tails++
+				}
+			}
+			modified, err := applyWithEvanphx(t, a, patch)
+			assert.NoError(t, err)
+			if !assert.JSONEq(t, string(b), string(modified), "%s -> %s: %v", a, b, patch) {
+				return
+			}
+		}
+	}
+	assert.NotZero(t, tails)
+}
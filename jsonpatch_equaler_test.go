@@ -0,0 +1,108 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithEqualer(t *testing.T) {
+	a := `{
+		"sensors": [{"id": "s1", "reading": 20.01}, {"id": "s2", "reading": 18.5}],
+		"users": [{"email": "Ann@Example.com", "seen": "2021-02-03T04:05:06Z"}],
+		"price": 10
+	}`
+	b := `{
+		"sensors": [{"id": "s1", "reading": 20.02}, {"id": "s2", "reading": 19.5}],
+		"users": [{"email": "ann@example.com", "seen": "2021-02-03T05:05:06+01:00"}],
+		"price": 10.01
+	}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b),
+		WithEqualer("/sensors/*/reading", EqualWithin(0.05)),
+		WithEqualer("/users/*/email", EqualFold()),
+		WithEqualer("/users/*/seen", EqualTime(time.RFC3339)))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{
+		NewPatch("replace", "/price", 10.01),
+		NewPatch("replace", "/sensors/1/reading", 19.5),
+	}, patch)
+
+	patch, err = CreatePatchWithOptions([]byte(a), []byte(b), WithMinimisation(false))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(patch))
+}
+
+func TestEqualerOnGeoJSON(t *testing.T) {
+	a := `{"type":"LineString", "coordinates":[[0.0, 1.0], [2.0, 3.0]]}`
+	b := `{"type":"LineString", "coordinates":[[0.0000001, 1.0], [2.0, 2.9999999]]}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithEqualer("/coordinates", EqualWithin(1e-6)))
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+
+	// the same pattern matching each position
+	patch, err = CreatePatchWithOptions([]byte(a), []byte(b), WithEqualer("/coordinates/*", EqualWithin(1e-6)))
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+
+	c := `{"type":"LineString", "coordinates":[[0.0, 1.0], [2.0, 3.0], [4.0, 5.0]]}`
+	patch, err = CreatePatchWithOptions([]byte(b), []byte(c), WithEqualer("/coordinates/*", EqualWithin(1e-6)))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("add", "/coordinates/2", []interface{}{4.0, 5.0})}, patch)
+}
+
+func TestEqualers(t *testing.T) {
+	within := EqualWithin(0.5)
+	assert.True(t, within.Equal(1.0, 1.4))
+	assert.True(t, within.Equal(json.Number("1"), 1.5))
+	assert.False(t, within.Equal(1.0, 1.6))
+	assert.True(t, within.Equal([]interface{}{1.0, "a"}, []interface{}{1.2, "a"}))
+	assert.False(t, within.Equal("a", "b"))
+
+	fold := EqualFold()
+	assert.True(t, fold.Equal("ABC", "abc"))
+	assert.False(t, fold.Equal("abc", "abd"))
+	assert.True(t, fold.Equal(1.0, json.Number("1")))
+	assert.False(t, fold.Equal("1", 1.0))
+
+	instant := EqualTime(time.RFC3339)
+	assert.True(t, instant.Equal("2021-02-03T04:05:06Z", "2021-02-03T06:05:06+02:00"))
+	assert.False(t, instant.Equal("2021-02-03T04:05:06Z", "2021-02-03T04:05:07Z"))
+	assert.True(t, instant.Equal("yesterday", "yesterday"))
+	assert.False(t, instant.Equal("yesterday", "2021-02-03T04:05:06Z"))
+}
+
+func TestCustomEqualer(t *testing.T) {
+	// versions are compared without their build metadata
+	version := EqualerFunc(func(a, b interface{}) bool {
+		as, _ := a.(string)
+		bs, _ := b.(string)
+		return len(as) >= 5 && len(bs) >= 5 && as[:5] == bs[:5]
+	})
+	a := `{"version": "1.2.3+abc", "kind": "x"}`
+	b := `{"version": "1.2.3+def", "kind": 1}`
+
+	var events []TraceEvent
+	tracer := TracerFunc(func(e TraceEvent) { events = append(events, e) })
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithEqualer("/version", version), WithEqualer("/*", EqualFold()), WithTracer(tracer))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "/kind", 1.0)}, patch)
+	assert.Contains(t, events, TraceEvent{Differ: "value", Decision: TraceKeep, Path: "/version", Detail: "equal according to its Equaler"})
+
+	_, err = CreatePatchWithOptions([]byte(a), []byte(b), WithEqualer("version", version))
+	assert.Error(t, err)
+}
+
+func TestEqualElementsWithoutEqualers(t *testing.T) {
+	d := newDiffer()
+	a, b := map[string]interface{}{"x": 1.0}, map[string]interface{}{"x": 1.0}
+	allocs := testing.AllocsPerRun(100, func() {
+		d.equalElements(a, b, "/list", 12)
+	})
+	assert.Zero(t, allocs)
+
+	d = newDiffer(WithEqualer("/list/*", EqualFold()))
+	assert.True(t, d.equalElements("A", "a", "/list", 12))
+	assert.False(t, d.equalElements("A", "b", "/list", 12))
+}
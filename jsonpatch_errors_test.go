@@ -0,0 +1,100 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/herkyl/jsonpatch/pointer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidDocumentError(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		which  string
+		offset int64
+	}{
+		{`{"a":1}`, `{"a":}`, "modified", 6},
+		{`[1, 2`, `[]`, "original", 5},
+		{``, `{}`, "original", 0},
+	}
+	for _, tc := range cases {
+		_, err := CreatePatch([]byte(tc.a), []byte(tc.b))
+		var docErr *InvalidDocumentError
+		if assert.True(t, errors.As(err, &docErr), "%v", err) {
+			assert.Equal(t, tc.which, docErr.Which)
+			assert.Equal(t, tc.offset, docErr.Offset)
+			assert.Error(t, docErr.Cause)
+		}
+	}
+
+	_, err := ApplyMergePatch([]byte(`{}`), []byte(`{"a"`))
+	var docErr *InvalidDocumentError
+	assert.True(t, errors.As(err, &docErr))
+	assert.Equal(t, "patch", docErr.Which)
+}
+
+func TestApplyErrorTypes(t *testing.T) {
+	doc := []byte(`{"a":{"b":[1, 2]}, "c":"d"}`)
+
+	_, err := Apply(doc, []JSONPatchOperation{
+		{Operation: "test", Path: "/c", Value: "d"},
+		{Operation: "remove", Path: "/a/x"},
+	})
+	var notFound *PathNotFoundError
+	if assert.True(t, errors.As(err, &notFound), "%v", err) {
+		assert.Equal(t, 1, notFound.Index)
+		assert.Equal(t, "remove", notFound.Operation)
+		assert.Equal(t, "/a/x", notFound.Path)
+		assert.True(t, errors.Is(err, pointer.ErrKeyNotFound))
+	}
+
+	_, err = Apply(doc, []JSONPatchOperation{{Operation: "copy", From: "/a/b/5", Path: "/e"}})
+	if assert.True(t, errors.As(err, &notFound), "%v", err) {
+		assert.Equal(t, "/a/b/5", notFound.Path)
+		assert.True(t, errors.Is(err, pointer.ErrIndexOutOfRange))
+	}
+
+	_, err = Apply(doc, []JSONPatchOperation{{Operation: "test", Path: "/a/b/0", Value: 2}})
+	var testErr *TestFailedError
+	if assert.True(t, errors.As(err, &testErr), "%v", err) {
+		assert.Equal(t, 0, testErr.Index)
+		assert.Equal(t, "/a/b/0", testErr.Path)
+		assert.Equal(t, json.Number("2"), testErr.Expected)
+		assert.Equal(t, json.Number("1"), testErr.Actual)
+	}
+
+	invalid := []JSONPatchOperation{
+		{Operation: "frobnicate", Path: "/a"},
+		{Operation: "add", Path: "a", Value: 1},
+		{Operation: "move", From: "/a", Path: "/a/b/0"},
+		{Operation: "remove", Path: ""},
+		{Operation: "remove", Path: "/a/b/01"},
+		{Operation: "replace", Path: "/a/b/-", Value: 3},
+		{Operation: "copy", From: "/a/b/-", Path: "/e"},
+	}
+	for _, op := range invalid {
+		_, err = Apply(doc, []JSONPatchOperation{op})
+		var opErr *InvalidOperationError
+		if assert.True(t, errors.As(err, &opErr), "%v", err) {
+			assert.Equal(t, op.Operation, opErr.Operation)
+			assert.Equal(t, op.Path, opErr.Path)
+		}
+	}
+}
+
+func TestInvertAndMergeErrorTypes(t *testing.T) {
+	var opErr *InvalidOperationError
+	_, err := Invert([]JSONPatchOperation{NewPatch("add", "/a", 1), NewPatch("add", "/list/-", 1)})
+	if assert.True(t, errors.As(err, &opErr), "%v", err) {
+		assert.Equal(t, 1, opErr.Index)
+	}
+
+	_, err = ConvertToMergePatch([]byte(`{"a":[1]}`), []JSONPatchOperation{NewPatch("remove", "/a/0", nil)})
+	assert.True(t, errors.As(err, &opErr), "%v", err)
+
+	var notFound *PathNotFoundError
+	_, err = ConvertToMergePatch([]byte(`{}`), []JSONPatchOperation{NewPatch("remove", "/a", nil)})
+	assert.True(t, errors.As(err, &notFound), "%v", err)
+}
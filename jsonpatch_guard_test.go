@@ -0,0 +1,78 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParentTestOperations(t *testing.T) {
+	a := fmt.Sprintf(`{"a":{"b":{"c":1, "d":2}, "l":"%s"}, "e":[{"f":1, "l":"%s"}, 2], "g":"%s"}`, lorem, lorem, lorem)
+	b := fmt.Sprintf(`{"a":{"b":{"c":2}, "l":"%s"}, "e":[{"f":2, "l":"%s"}, 2], "g":"%s"}`, lorem, lorem, lorem)
+	patch, e := CreatePatchWithOptions([]byte(a), []byte(b), WithParentTestOperations(), WithMinimisation(false))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 5, len(patch))
+
+	assert.Equal(t, "test", patch[0].Operation)
+	assert.Equal(t, "/a/b", patch[0].Path)
+	assert.Equal(t, map[string]interface{}{"c": float64(1), "d": float64(2)}, patch[0].Value)
+	assert.Equal(t, "test", patch[1].Operation)
+	assert.Equal(t, "/e", patch[1].Path)
+
+	modified, e := Apply([]byte(a), patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, b, string(modified))
+
+	concurrent := fmt.Sprintf(`{"a":{"b":{"c":1, "d":3}, "l":"%s"}, "e":[{"f":1, "l":"%s"}, 2], "g":"%s"}`, lorem, lorem, lorem)
+	_, e = Apply([]byte(concurrent), patch)
+	assert.Error(t, e)
+}
+
+func TestParentTestOperationsWholeDocument(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(`[1]`), []byte(`{"a":1}`), WithParentTestOperations())
+	assert.NoError(t, e)
+	assert.Equal(t, []JSONPatchOperation{
+		NewPatch("test", "", []interface{}{float64(1)}),
+		NewPatch("replace", "", map[string]interface{}{"a": float64(1)}),
+	}, patch)
+
+	patch, e = CreatePatchWithOptions([]byte(`[1]`), []byte(`[1]`), WithParentTestOperations())
+	assert.NoError(t, e)
+	assert.Equal(t, 0, len(patch))
+}
+
+// TestGuardedPatches creates guarded patches for the documents in tests.json
+// and checks they still apply to the original document.
+func TestGuardedPatches(t *testing.T) {
+	file, err := ioutil.ReadFile("tests.json")
+	assert.NoError(t, err)
+
+	var applyTests []applyTest
+	err = json.Unmarshal(file, &applyTests)
+	assert.NoError(t, err)
+
+	for i, tc := range applyTests {
+		if tc.Disabled || tc.Error != nil || tc.Expected == nil {
+			continue
+		}
+		testName := fmt.Sprintf(`Test #%d %s`, i, tc.Comment)
+		t.Run(testName, func(t *testing.T) {
+			for _, opt := range []Option{WithTestOperations(), WithParentTestOperations()} {
+				patch, err := CreatePatchWithOptions(tc.Doc, tc.Expected, opt, WithMinimisation(false))
+				assert.NoError(t, err)
+				for i, op := range patch {
+					if op.Operation == "replace" || op.Operation == "remove" {
+						assert.True(t, i > 0 && patch[i-1].Operation == "test" || patch[0].Operation == "test")
+					}
+				}
+				modified, err := Apply(tc.Doc, patch)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(tc.Expected), string(modified))
+			}
+		})
+	}
+}
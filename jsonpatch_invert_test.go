@@ -0,0 +1,136 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvert(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(simpleA), []byte(simplef), WithOldValues())
+	assert.NoError(t, e)
+	inverse, e := Invert(patch)
+	assert.NoError(t, e)
+	b, err := json.Marshal(inverse)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"op":"replace","path":"","value":{"a":100,"b":200,"c":"hello"}}]`, string(b))
+
+	patch, e = CreatePatchWithOptions([]byte(simpleA), []byte(simplef), WithOldValues(), WithMinimisation(false))
+	assert.NoError(t, e)
+	inverse, e = Invert(patch)
+	assert.NoError(t, e)
+	b, err = json.Marshal(inverse)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"op":"remove","path":"/d"},{"op":"replace","path":"/b","value":200},{"op":"add","path":"/c","value":"hello"}]`, string(b))
+}
+
+func TestInvertFromTestOperations(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(simpleA), []byte(simplef), WithTestOperations(), WithMinimisation(false))
+	assert.NoError(t, e)
+	inverse, e := Invert(patch)
+	assert.NoError(t, e)
+
+	modified, e := Apply([]byte(simplef), inverse)
+	assert.NoError(t, e)
+	assert.JSONEq(t, simpleA, string(modified))
+}
+
+func TestInvertMoveAndCopy(t *testing.T) {
+	doc := `{"a":[1, 2, 3], "b":{"c":1}}`
+	patch := []JSONPatchOperation{
+		{Operation: "move", From: "/a/0", Path: "/a/2"},
+		{Operation: "copy", From: "/b", Path: "/d"},
+		{Operation: "move", From: "/b/c", Path: "/e"},
+	}
+	modified, e := Apply([]byte(doc), patch)
+	assert.NoError(t, e)
+	inverse, e := Invert(patch)
+	assert.NoError(t, e)
+	modified, e = Apply(modified, inverse)
+	assert.NoError(t, e)
+	assert.JSONEq(t, doc, string(modified))
+
+	_, e = Invert([]JSONPatchOperation{NewPatch("add", "/a/-", 1)})
+	assert.Error(t, e)
+}
+
+func TestInvertWithoutOldValues(t *testing.T) {
+	for _, op := range []JSONPatchOperation{
+		NewPatch("remove", "/a", nil),
+		NewPatch("replace", "/a", 1),
+	} {
+		_, e := Invert([]JSONPatchOperation{NewPatch("add", "/b", 1), op})
+		var opErr *InvalidOperationError
+		if assert.True(t, errors.As(e, &opErr), "%v", e) {
+			assert.Equal(t, 1, opErr.Index)
+			assert.Equal(t, op.Operation, opErr.Operation)
+		}
+	}
+
+	// a recorded null is an old value like any other
+	doc := `{"a":null, "b":1}`
+	patch, e := CreatePatchWithOptions([]byte(doc), []byte(`{"b":1}`), WithOldValues())
+	assert.NoError(t, e)
+	inverse, e := Invert(patch)
+	assert.NoError(t, e)
+	modified, e := Apply([]byte(`{"b":1}`), inverse)
+	assert.NoError(t, e)
+	assert.JSONEq(t, doc, string(modified))
+
+	inverse, e = Invert([]JSONPatchOperation{NewPatch("test", "/a", nil), NewPatch("remove", "/a", nil)})
+	assert.NoError(t, e)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("add", "/a", nil)}, inverse)
+}
+
+func TestInvertRandomDocuments(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		a, _ := json.Marshal(randomValue(r, 3))
+		b, _ := json.Marshal(randomValue(r, 3))
+		for _, opts := range [][]Option{
+			{WithOldValues()},
+			{WithOldValues(), WithMinimisation(false)},
+		} {
+			patch, e := CreatePatchWithOptions(a, b, opts...)
+			assert.NoError(t, e)
+			inverse, e := Invert(patch)
+			assert.NoError(t, e)
+
+			modified, e := Apply(a, patch)
+			assert.NoError(t, e)
+			assert.JSONEq(t, string(b), string(modified))
+			modified, e = Apply(modified, inverse)
+			assert.NoError(t, e)
+			if !assert.JSONEq(t, string(a), string(modified), "patch %v inverse %v", patch, inverse) {
+				return
+			}
+		}
+	}
+}
+
+// randomValue returns a random json value nested up to depth levels, drawn
+// from a small set of keys and values so that documents overlap.
+func randomValue(r *rand.Rand, depth int) interface{} {
+	switch n := r.Intn(6); {
+	case depth > 0 && n < 2:
+		o := map[string]interface{}{}
+		for i := r.Intn(5); i > 0; i-- {
+			o[fmt.Sprintf("k%d", r.Intn(5))] = randomValue(r, depth-1)
+		}
+		return o
+	case depth > 0 && n < 4:
+		a := make([]interface{}, r.Intn(5))
+		for i := range a {
+			a[i] = randomValue(r, depth-1)
+		}
+		return a
+	case n == 4:
+		return nil
+	default:
+		return fmt.Sprintf("v%d", r.Intn(4))
+	}
+}
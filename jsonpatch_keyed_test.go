@@ -0,0 +1,85 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	keyedBase = fmt.Sprintf(`{"users":[
+	{"id":1, "name":"Ed", "bio":"%s"},
+	{"id":2, "name":"Sally", "bio":"%s"},
+	{"id":3, "name":"Bob", "bio":"%s"}
+]}`, lorem, lorem, lorem)
+
+	keyedUpdated = fmt.Sprintf(`{"users":[
+	{"id":3, "name":"Bob", "bio":"%s"},
+	{"id":1, "name":"Eddie", "bio":"%s"},
+	{"id":4, "name":"Ann", "bio":"%s"}
+]}`, lorem, lorem, lorem)
+)
+
+func TestArrayKey(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(keyedBase), []byte(keyedUpdated), WithArrayKey("/users", "id"))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 4, len(patch))
+
+	change := patch[0]
+	assert.Equal(t, "remove", change.Operation)
+	assert.Equal(t, "/users/1", change.Path)
+
+	change = patch[1]
+	assert.Equal(t, "move", change.Operation)
+	assert.Equal(t, "/users/0", change.From)
+	assert.Equal(t, "/users/1", change.Path)
+
+	change = patch[2]
+	assert.Equal(t, "add", change.Operation)
+	assert.Equal(t, "/users/2", change.Path)
+
+	change = patch[3]
+	assert.Equal(t, "replace", change.Operation)
+	assert.Equal(t, "/users/1/name", change.Path)
+	assert.Equal(t, "Eddie", change.Value)
+
+	modified, e := Apply([]byte(keyedBase), patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, keyedUpdated, string(modified))
+}
+
+func TestArrayKeyPattern(t *testing.T) {
+	a := fmt.Sprintf(`{"groups":[{"members":[{"name":"a", "x":1, "l":"%s"}, {"name":"b", "x":1, "l":"%s"}]}]}`, lorem, lorem)
+	b := fmt.Sprintf(`{"groups":[{"members":[{"name":"b", "x":2, "l":"%s"}]}]}`, lorem)
+	patch, e := CreatePatchWithOptions([]byte(a), []byte(b), WithArrayKey("/groups/*/members", "name"))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 2, len(patch))
+	assert.Equal(t, "remove", patch[0].Operation)
+	assert.Equal(t, "/groups/0/members/0", patch[0].Path)
+	assert.Equal(t, "replace", patch[1].Operation)
+	assert.Equal(t, "/groups/0/members/0/x", patch[1].Path)
+
+	modified, e := Apply([]byte(a), patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, b, string(modified))
+}
+
+func TestArrayKeyMixedElements(t *testing.T) {
+	a := `[{"id":"x", "v":1}, "x", 1, {"v":2}]`
+	b := `["x", {"v":2}, {"id":"x", "v":3}, 2]`
+	patch, e := CreatePatchWithOptions([]byte(a), []byte(b), WithArrayKey("", "id"))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+
+	modified, e := Apply([]byte(a), patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, b, string(modified))
+}
+
+func TestArrayKeyInvalidPattern(t *testing.T) {
+	_, e := CreatePatchWithOptions([]byte(`[]`), []byte(`[]`), WithArrayKey("users", "id"))
+	assert.Error(t, e)
+}
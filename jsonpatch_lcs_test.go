@@ -0,0 +1,126 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditScript(t *testing.T) {
+	cases := map[string]struct {
+		a        string
+		b        string
+		expected []edit
+	}{
+		"equal":   {`abc`, `abc`, []edit{editKeep, editKeep, editKeep}},
+		"empty":   {``, ``, nil},
+		"insert":  {`ac`, `abc`, []edit{editKeep, editInsert, editKeep}},
+		"delete":  {`abc`, `ac`, []edit{editKeep, editDelete, editKeep}},
+		"replace": {`a`, `b`, []edit{editDelete, editInsert}},
+		"myers": {`abcabba`, `cbabac`, []edit{
+			editDelete, editDelete, editKeep, editInsert, editKeep, editKeep, editDelete, editKeep, editInsert,
+		}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, editScript(letters(tc.a), letters(tc.b)))
+		})
+	}
+}
+
+func letters(s string) []interface{} {
+	l := []interface{}{}
+	for _, c := range s {
+		l = append(l, string(c))
+	}
+	return l
+}
+
+func TestLCSIsMinimal(t *testing.T) {
+	a := []interface{}{"a", "b", "c", "a", "b", "b", "a"}
+	b := []interface{}{"c", "b", "a", "b", "a", "c"}
+	patch, e := newDiffer().diffArraysLCS(a, b, "", editScript(a, b))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 5, len(patch))
+}
+
+func TestLCSDiffsChangedObjects(t *testing.T) {
+	patch, e := CreatePatch(
+		[]byte(fmt.Sprintf(`[{"a":1, "l":"%s"}, {"b":2, "l":"%s"}]`, lorem, lorem)),
+		[]byte(fmt.Sprintf(`[{"a":1, "l":"%s"}, {"b":3, "l":"%s"}, 4]`, lorem, lorem)),
+	)
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 2, len(patch))
+
+	change := patch[0]
+	assert.Equal(t, "replace", change.Operation)
+	assert.Equal(t, "/1/b", change.Path)
+	assert.Equal(t, float64(3), change.Value)
+
+	change = patch[1]
+	assert.Equal(t, "add", change.Operation)
+	assert.Equal(t, "/2", change.Path)
+	assert.Equal(t, float64(4), change.Value)
+}
+
+func TestGreedyArrayStrategy(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(`[1, 2, 3]`), []byte(`[0, 1, 2, 3]`), WithArrayStrategy(ArrayGreedy))
+	assert.NoError(t, e)
+	patchBytes, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"op":"add","path":"/0","value":0}]`, string(patchBytes))
+}
+
+func TestLCSRandomArrays(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		a := randomArray(r)
+		b := randomArray(r)
+		ab, _ := json.Marshal(a)
+		bb, _ := json.Marshal(b)
+
+		patch, e := CreatePatch(ab, bb)
+		assert.NoError(t, e)
+		modified, e := Apply(ab, patch)
+		assert.NoError(t, e)
+		assert.JSONEq(t, string(bb), string(modified), "patch %v", patch)
+	}
+}
+
+func TestArrayStrategiesRandomDocuments(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for n := 0; n < 1000; n++ {
+		a, _ := json.Marshal(randomValue(r, 3))
+		b, _ := json.Marshal(randomValue(r, 3))
+		for _, strategy := range []ArrayStrategy{ArrayLCS, ArrayGreedy} {
+			patch, e := CreatePatchWithOptions(a, b, WithArrayStrategy(strategy))
+			if !assert.NoError(t, e) {
+				continue
+			}
+			modified, e := Apply(a, patch)
+			assert.NoError(t, e)
+			assert.JSONEq(t, string(b), string(modified), "strategy %d: %s -> %s: %v", strategy, a, b, patch)
+		}
+	}
+}
+
+func randomArray(r *rand.Rand) []interface{} {
+	a := make([]interface{}, r.Intn(10))
+	for i := range a {
+		switch r.Intn(3) {
+		case 0:
+			a[i] = r.Intn(4)
+		case 1:
+			a[i] = map[string]interface{}{"k": r.Intn(3), "l": lorem[:r.Intn(60)]}
+		default:
+			a[i] = []interface{}{r.Intn(3), r.Intn(3)}
+		}
+	}
+	return a
+}
@@ -0,0 +1,103 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The examples from http://tools.ietf.org/html/rfc7386#appendix-A
+var mergePatchExamples = []struct {
+	original string
+	patch    string
+	result   string
+}{
+	{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b", "b":"c"}`},
+	{`{"a":"b"}`, `{"a":null}`, `{}`},
+	{`{"a":"b", "b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+	{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+	{`{"a":{"b":"c"}}`, `{"a":{"b":"d", "c":null}}`, `{"a":{"b":"d"}}`},
+	{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+	{`["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+	{`{"a":"b"}`, `["c"]`, `["c"]`},
+	{`{"a":"foo"}`, `null`, `null`},
+	{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	{`{"e":null}`, `{"a":1}`, `{"e":null, "a":1}`},
+	{`[1, 2]`, `{"a":"b", "c":null}`, `{"a":"b"}`},
+	{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	for _, tc := range mergePatchExamples {
+		result, e := ApplyMergePatch([]byte(tc.original), []byte(tc.patch))
+		assert.NoError(t, e)
+		assert.JSONEq(t, tc.result, string(result), tc.patch)
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	cases := []struct {
+		a     string
+		b     string
+		patch string
+	}{
+		{simpleA, simplef, `{"b":100, "c":null, "d":"foo"}`},
+		{complexBase, complexC, `{"k":[{"l":"m"}, {"l":"o"}]}`},
+		{`{"a":{"b":1, "c":{"d":2}}}`, `{"a":{"b":1, "c":{"e":3}}}`, `{"a":{"c":{"d":null, "e":3}}}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+		{`{"a":[1, {"b":null}]}`, `{"a":[{"b":null}]}`, `{"a":[{"b":null}]}`},
+	}
+	for _, tc := range cases {
+		patch, e := CreateMergePatch([]byte(tc.a), []byte(tc.b))
+		assert.NoError(t, e)
+		assert.JSONEq(t, tc.patch, string(patch))
+
+		result, e := ApplyMergePatch([]byte(tc.a), patch)
+		assert.NoError(t, e)
+		assert.JSONEq(t, tc.b, string(result))
+	}
+
+	_, e := CreateMergePatch([]byte(simpleA), []byte(simpleG))
+	assert.Error(t, e)
+	_, e = CreateMergePatch([]byte(`{}`), []byte(`{"a":{"b":null}}`))
+	assert.Error(t, e)
+	_, e = CreateMergePatch([]byte(`{}`), []byte(`{"a"`))
+	assert.IsType(t, &InvalidDocumentError{}, e)
+}
+
+func TestConvertToMergePatch(t *testing.T) {
+	doc := []byte(`{"a":{"b":1, "c":[1, 2]}, "d":"e"}`)
+	patch := []JSONPatchOperation{
+		NewPatch("replace", "/a/b", 2),
+		NewPatch("remove", "/d", nil),
+		NewPatch("replace", "/a/c", []interface{}{3}),
+		{Operation: "move", From: "/a/b", Path: "/f"},
+	}
+	mergePatch, e := ConvertToMergePatch(doc, patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, `{"a":{"b":null, "c":[3]}, "d":null, "f":2}`, string(mergePatch))
+
+	expected, e := Apply(doc, patch)
+	assert.NoError(t, e)
+	result, e := ApplyMergePatch(doc, mergePatch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, string(expected), string(result))
+}
+
+func TestConvertToMergePatchNotRepresentable(t *testing.T) {
+	doc := []byte(`{"a":{"b":1, "c":[1, 2]}}`)
+	cases := map[string][]JSONPatchOperation{
+		"array element": {NewPatch("add", "/a/c/0", 0)},
+		"array move":    {{Operation: "move", From: "/a/c/1", Path: "/d"}},
+		"null":          {NewPatch("replace", "/a/b", nil)},
+		"nested null":   {NewPatch("add", "/d", map[string]interface{}{"e": nil})},
+		"test":          {NewPatch("test", "/a/b", 1)},
+		"failing":       {NewPatch("remove", "/x", nil)},
+	}
+	for name, patch := range cases {
+		_, e := ConvertToMergePatch(doc, patch)
+		assert.Error(t, e, name)
+	}
+}
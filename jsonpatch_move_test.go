@@ -0,0 +1,80 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveArrayElement(t *testing.T) {
+	a := fmt.Sprintf(`{"list":[{"id":1, "text":"%s"}, {"id":2, "text":"%s"}, {"id":3, "text":"%s"}]}`, lorem, lorem, lorem)
+	b := fmt.Sprintf(`{"list":[{"id":3, "text":"%s"}, {"id":1, "text":"%s"}, {"id":2, "text":"%s"}]}`, lorem, lorem, lorem)
+	patch, e := CreatePatch([]byte(a), []byte(b))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 1, len(patch))
+
+	change := patch[0]
+	assert.Equal(t, "move", change.Operation)
+	assert.Equal(t, "/list/2", change.From)
+	assert.Equal(t, "/list/0", change.Path)
+	assert.Equal(t, nil, change.Value)
+}
+
+func TestMoveObjectMember(t *testing.T) {
+	a := fmt.Sprintf(`{"a":{"old":{"text":"%s"}}}`, lorem)
+	b := fmt.Sprintf(`{"a":{"new":{"text":"%s"}}}`, lorem)
+	patch, e := CreatePatch([]byte(a), []byte(b))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 1, len(patch))
+
+	change := patch[0]
+	assert.Equal(t, "move", change.Operation)
+	assert.Equal(t, "/a/old", change.From)
+	assert.Equal(t, "/a/new", change.Path)
+	assert.Equal(t, `{"op":"move","path":"/a/new","from":"/a/old"}`, change.JSON())
+}
+
+func TestCopyUnchangedValue(t *testing.T) {
+	a := fmt.Sprintf(`{"a":{"text":"%s"}, "b":1}`, lorem)
+	b := fmt.Sprintf(`{"a":{"text":"%s"}, "b":1, "c":{"text":"%s"}}`, lorem, lorem)
+	patch, e := CreatePatch([]byte(a), []byte(b))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 1, len(patch))
+
+	change := patch[0]
+	assert.Equal(t, "copy", change.Operation)
+	assert.Equal(t, "/a", change.From)
+	assert.Equal(t, "/c", change.Path)
+}
+
+func TestMoveAllPermutations(t *testing.T) {
+	a := []interface{}{"a", "b", "c", "d", "e"}
+	var permute func(b []interface{}, rest []interface{})
+	permute = func(b []interface{}, rest []interface{}) {
+		for i := range rest {
+			next := append(append([]interface{}{}, b...), rest[i])
+			others := append(append([]interface{}{}, rest[:i]...), rest[i+1:]...)
+			permute(next, others)
+			// also drop the element and add a new one in its place
+			permute(append(append([]interface{}{}, b...), "x"), others)
+		}
+		if len(rest) > 0 {
+			return
+		}
+		patch := newDiffer().diffArrayMoves(a, b, "", editScript(a, b))
+		if patch == nil {
+			return
+		}
+		ab, _ := json.Marshal(a)
+		bb, _ := json.Marshal(b)
+		modified, e := Apply(ab, patch)
+		assert.NoError(t, e)
+		assert.JSONEq(t, string(bb), string(modified), "patch %v", patch)
+	}
+	permute(nil, a)
+}
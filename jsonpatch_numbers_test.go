@@ -0,0 +1,66 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberModes(t *testing.T) {
+	large := []byte(`{"id":9007199254740993, "amount":1.0}`)
+	largeModified := []byte(`{"id":9007199254740992, "amount":1}`)
+
+	patch, err := CreatePatch(large, largeModified)
+	assert.NoError(t, err)
+	assert.Empty(t, patch, "float64 cannot tell the ids apart")
+
+	patch, err = CreatePatchWithOptions(large, largeModified, WithNumberMode(NumberExact))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(patch)) {
+		assert.Equal(t, `{"op":"replace","path":"/id","value":9007199254740992}`, patch[0].JSON())
+		assert.Equal(t, json.Number("9007199254740992"), patch[0].Value)
+	}
+
+	patch, err = CreatePatchWithOptions(large, largeModified, WithNumberMode(NumberLexical), WithMinimisation(false))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(patch)) {
+		assert.Equal(t, `{"op":"replace","path":"/amount","value":1}`, patch[0].JSON())
+		assert.Equal(t, `{"op":"replace","path":"/id","value":9007199254740992}`, patch[1].JSON())
+	}
+
+	patch, err = CreatePatchWithOptions([]byte(`[1e2, 0.10]`), []byte(`[100, 0.1]`), WithNumberMode(NumberExact))
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+
+	patch, err = CreatePatchWithOptions(large, largeModified, WithNumberMode(NumberLexical), WithFloatTolerance(1))
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestNumbersKeepPrecisionEndToEnd(t *testing.T) {
+	a := []byte(`{"id":12345678901234567890, "items":[{"price":0.1000000000000000055511151231257827}]}`)
+	b := []byte(`{"id":12345678901234567891, "items":[{"price":0.1000000000000000055511151231257828}]}`)
+	patch, err := CreatePatchWithOptions(a, b, WithNumberMode(NumberExact), WithMinimisation(false))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(patch))
+
+	encoded, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	decoded, err := DecodePatch(encoded)
+	assert.NoError(t, err)
+
+	modified, err := Apply(a, decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":12345678901234567891,"items":[{"price":0.1000000000000000055511151231257828}]}`, string(modified))
+
+	// decoding into the operations themselves keeps float64 values
+	var plain []JSONPatchOperation
+	assert.NoError(t, json.Unmarshal(encoded, &plain))
+	assert.IsType(t, float64(0), plain[0].Value)
+
+	_, err = Apply(a, []JSONPatchOperation{{Operation: "test", Path: "/id", Value: json.Number("12345678901234567891")}})
+	assert.Error(t, err)
+	_, err = Apply(a, []JSONPatchOperation{{Operation: "test", Path: "/id", Value: json.Number("1.2345678901234567890e19")}})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithoutMinimisation(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(`{"a":[1, 2, 3]}`), []byte(`{"a":[1, 0, 0]}`), WithMinimisation(false))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 4, len(patch))
+	for _, p := range patch {
+		assert.NotEqual(t, "/a", p.Path)
+	}
+}
+
+func TestWithTestOperations(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(simpleA), []byte(simplef), WithTestOperations(), WithMinimisation(false))
+	assert.NoError(t, e)
+	b, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"op":"test","path":"/c","value":"hello"},{"op":"remove","path":"/c"},{"op":"test","path":"/b","value":200},{"op":"replace","path":"/b","value":100},{"op":"add","path":"/d","value":"foo"}]`, string(b))
+
+	modified, e := Apply([]byte(simpleA), patch)
+	assert.NoError(t, e)
+	assert.JSONEq(t, simplef, string(modified))
+
+	_, e = Apply([]byte(simpleC), patch)
+	assert.Error(t, e)
+}
+
+func TestWithIgnoredPaths(t *testing.T) {
+	a := `{"metadata":{"name":"x", "updatedAt":"2020"}, "etag":"1", "spec":{"replicas":1}}`
+	b := `{"metadata":{"name":"x", "updatedAt":"2021"}, "etag":"2", "spec":{"replicas":2}}`
+	patch, e := CreatePatchWithOptions([]byte(a), []byte(b), WithIgnoredPaths("/metadata/updatedAt", "/etag"))
+	assert.NoError(t, e)
+	t.Log("Patch:", patch)
+	assert.Equal(t, 1, len(patch))
+	assert.Equal(t, "replace", patch[0].Operation)
+	assert.Equal(t, "/spec/replicas", patch[0].Path)
+
+	patch, e = CreatePatchWithOptions([]byte(`{"a":{"b":1, "c":1}}`), []byte(`{"a":{"b":2, "c":2}}`), WithIgnoredPaths("/a/c"))
+	assert.NoError(t, e)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "/a/b", float64(2))}, patch)
+
+	_, e = CreatePatchWithOptions([]byte(a), []byte(b), WithIgnoredPaths("etag"))
+	assert.Error(t, e)
+}
+
+func TestWithFloatTolerance(t *testing.T) {
+	a := `{"temperature":21.0001, "readings":[1.0, 2.0], "name":"a"}`
+	b := `{"temperature":21.0002, "readings":[1.0002, 2.0], "name":"b"}`
+	patch, e := CreatePatchWithOptions([]byte(a), []byte(b), WithFloatTolerance(0.001))
+	assert.NoError(t, e)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "/name", "b")}, patch)
+
+	patch, e = CreatePatchWithOptions([]byte(a), []byte(b), WithMinimisation(false))
+	assert.NoError(t, e)
+	assert.Equal(t, 4, len(patch))
+}
@@ -0,0 +1,53 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStableOrder(t *testing.T) {
+	cases := map[string]struct {
+		a string
+		b string
+	}{
+		"simple":        {simpleA, simplef},
+		"complex":       {complexBase, complexC},
+		"super complex": {superComplexBase, superComplexA},
+		"hyper complex": {hyperComplexBase, hyperComplexA},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var first string
+			for i := 0; i < 100; i++ {
+				patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithMinimisation(false))
+				assert.NoError(t, err)
+				b, err := json.Marshal(patch)
+				assert.NoError(t, err)
+				if i == 0 {
+					first = string(b)
+					continue
+				}
+				if !assert.Equal(t, first, string(b)) {
+					return
+				}
+			}
+
+			patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithMinimisation(false))
+			assert.NoError(t, err)
+			modified, err := Apply([]byte(tc.a), patch)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.b, string(modified))
+		})
+	}
+}
+
+func TestRemovesBeforeAdds(t *testing.T) {
+	patch, e := CreatePatchWithOptions([]byte(`{"b":1, "d":2, "e":{"x":1}}`), []byte(`{"a":1, "c":3, "e":{"y":1, "z":2}}`), WithMinimisation(false))
+	assert.NoError(t, e)
+	b, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"op":"remove","path":"/d"},{"op":"move","path":"/a","from":"/b"},{"op":"add","path":"/c","value":3},{"op":"move","path":"/e/y","from":"/e/x"},{"op":"add","path":"/e/z","value":2}]`, string(b))
+}
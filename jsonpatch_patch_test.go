@@ -0,0 +1,191 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchMethods(t *testing.T) {
+	ops, err := CreatePatchWithOptions([]byte(simpleA), []byte(simplef), WithOldValues(), WithMinimisation(false))
+	assert.NoError(t, err)
+	patch := Patch(ops)
+	assert.Equal(t, 3, patch.Len())
+	assert.Equal(t, []string{"/c", "/b", "/d"}, patch.Paths())
+	assert.NoError(t, patch.Validate())
+
+	modified, err := patch.Apply([]byte(simpleA))
+	assert.NoError(t, err)
+	assert.JSONEq(t, simplef, string(modified))
+
+	inverse, err := patch.Invert()
+	assert.NoError(t, err)
+	original, err := inverse.Apply(modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, simpleA, string(original))
+
+	removes := patch.Filter(func(op JSONPatchOperation) bool { return op.Operation == "remove" })
+	assert.Equal(t, []string{"/c"}, removes.Paths())
+	assert.Equal(t, 0, patch.Filter(func(JSONPatchOperation) bool { return false }).Len())
+}
+
+func TestPatchJSON(t *testing.T) {
+	doc := `[{"op":"add","path":"/a","value":{"b":[1,2]}},{"op":"move","from":"/a","path":"/c"},{"op":"remove","path":"/d"}]`
+	var patch Patch
+	assert.NoError(t, json.Unmarshal([]byte(doc), &patch))
+	assert.Equal(t, 3, patch.Len())
+	assert.Equal(t, "/a", patch[1].From)
+
+	b, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, doc, string(b))
+
+	b, err = json.Marshal(Patch(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(b))
+
+	b, err = json.Marshal(struct {
+		Patch Patch `json:"patch"`
+	}{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"patch":[]}`, string(b))
+
+	for _, doc := range []string{`null`, `{}`, `[{"op":"add"}]`, `[1]`} {
+		assert.Error(t, json.Unmarshal([]byte(doc), &patch), doc)
+	}
+}
+
+func TestPatchValidate(t *testing.T) {
+	cases := []struct {
+		op    JSONPatchOperation
+		valid bool
+	}{
+		{NewPatch("add", "/a/-", 1), true},
+		{NewPatch("remove", "", nil), true},
+		{JSONPatchOperation{Operation: "copy", From: "/a", Path: "/a/b"}, true},
+		{JSONPatchOperation{Operation: "move", From: "/a", Path: "/a"}, true},
+		{JSONPatchOperation{Operation: "move", From: "/a", Path: "/a/b"}, false},
+		{JSONPatchOperation{Operation: "move", From: "a", Path: "/b"}, false},
+		{NewPatch("add", "a", 1), false},
+		{NewPatch("delete", "/a", nil), false},
+		{NewPatch("", "/a", nil), false},
+	}
+	for _, tc := range cases {
+		err := Patch{NewPatch("test", "", nil), tc.op}.Validate()
+		if tc.valid {
+			assert.NoError(t, err, "%v", tc.op)
+			continue
+		}
+		var invalid *InvalidOperationError
+		if assert.True(t, errors.As(err, &invalid), "%v", tc.op) {
+			assert.Equal(t, 1, invalid.Index)
+			assert.Equal(t, tc.op.Path, invalid.Path)
+		}
+	}
+}
+
+func TestPatchString(t *testing.T) {
+	patch := Patch{
+		NewPatch("replace", "/name", "bob"),
+		NewPatch("add", "/tags/-", map[string]interface{}{"a": 1}),
+		NewPatch("remove", "/old", nil),
+		{Operation: "move", From: "/tags/2", Path: "/tags/0"},
+		{Operation: "copy", From: "/a", Path: "/b"},
+		NewPatch("test", "/n", nil),
+	}
+	assert.Equal(t, `replace /name "bob"
+add /tags/- {"a":1}
+remove /old
+move /tags/2 -> /tags/0
+copy /a -> /b
+test /n null`, patch.String())
+	assert.Equal(t, "", Patch{}.String())
+}
+
+func TestDecodePatch(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[
+		{"op":"add","path":"/a","value":null,"comment":"ignored"},
+		{"op":"copy","from":"/a","path":"/b"},
+		{"op":"test","path":"/n","value":1.10}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, Patch{
+		NewPatch("add", "/a", nil),
+		{Operation: "copy", From: "/a", Path: "/b"},
+		NewPatch("test", "/n", json.Number("1.10")),
+	}, patch)
+
+	patch, err = DecodePatch([]byte(`[]`))
+	assert.NoError(t, err)
+	assert.Equal(t, Patch{}, patch)
+
+	for _, doc := range []string{``, `null`, `{}`, `[1`} {
+		_, err := DecodePatch([]byte(doc))
+		var invalid *InvalidDocumentError
+		if assert.True(t, errors.As(err, &invalid), "%s: %v", doc, err) {
+			assert.Equal(t, "patch", invalid.Which)
+		}
+	}
+}
+
+func TestDecodePatchInvalidOperations(t *testing.T) {
+	cases := []struct {
+		op, operation, path string
+	}{
+		{`1`, "", ""},
+		{`{"path":"/a"}`, "", ""},
+		{`{"op":1,"path":"/a"}`, "", ""},
+		{`{"op":"spam","path":"/a","value":1}`, "spam", "/a"},
+		{`{"op":"add","value":1}`, "add", ""},
+		{`{"op":"add","path":null,"value":1}`, "add", ""},
+		{`{"op":"add","path":"/a"}`, "add", "/a"},
+		{`{"op":"test","path":"/a"}`, "test", "/a"},
+		{`{"op":"move","path":"/a"}`, "move", "/a"},
+		{`{"op":"copy","path":"/a","from":1}`, "copy", "/a"},
+		{`{"op":"remove","path":"a"}`, "remove", "a"},
+		{`{"op":"move","from":"/a~2","path":"/b"}`, "move", "/b"},
+		{`{"op":"move","from":"/a","path":"/a/b"}`, "move", "/a/b"},
+		{`{"op":"add","path":"/a","value":1,"value":2}`, "", ""},
+		{`{"op":"remove","op":"add","path":"/a"}`, "", ""},
+	}
+	for _, tc := range cases {
+		_, err := DecodePatch([]byte(`[{"op":"remove","path":"/x"},` + tc.op + `]`))
+		var invalid *InvalidOperationError
+		if assert.True(t, errors.As(err, &invalid), "%s: %v", tc.op, err) {
+			assert.Equal(t, 1, invalid.Index, tc.op)
+			assert.Equal(t, tc.operation, invalid.Operation, tc.op)
+			assert.Equal(t, tc.path, invalid.Path, tc.op)
+		}
+		var patch Patch
+		assert.Error(t, json.Unmarshal([]byte(`[`+tc.op+`]`), &patch), tc.op)
+	}
+}
+
+// TestDecodePatchTests decodes the patches in tests.json, which must succeed
+// unless applying the patch is expected to fail.
+func TestDecodePatchTests(t *testing.T) {
+	file, err := ioutil.ReadFile("tests.json")
+	assert.NoError(t, err)
+	var applyTests []applyTest
+	assert.NoError(t, json.Unmarshal(file, &applyTests))
+
+	for i, tc := range applyTests {
+		patch, err := DecodePatch(tc.Patch)
+		if err == nil {
+			_, err = patch.Apply(tc.Doc)
+		}
+		if tc.Error == nil {
+			assert.NoError(t, err, "test #%d %s", i, tc.Comment)
+		} else {
+			assert.Error(t, err, "test #%d %s", i, tc.Comment)
+		}
+	}
+
+	_, err = DecodePatch([]byte(`[{"op": "spam", "path": "/foo", "value": 1}]`))
+	var invalid *InvalidOperationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, "spam", invalid.Operation)
+}
@@ -0,0 +1,161 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pathsBase = `{
+	"metadata": {"name": "x", "updatedAt": "1"},
+	"etag": "1",
+	"spec": {"replicas": 1, "image": "a"},
+	"status": {"phase": "a", "ready": false},
+	"items": [{"id": 1, "lastSeen": "t1", "v": 1}, {"id": 2, "lastSeen": "t1", "v": 2}]
+}`
+
+var pathsModified = `{
+	"metadata": {"name": "y", "updatedAt": "2"},
+	"etag": "2",
+	"spec": {"replicas": 2, "image": "a"},
+	"status": {"phase": "b"},
+	"items": [{"id": 0, "v": 0}, {"id": 1, "lastSeen": "t2", "v": 1}, {"id": 2, "lastSeen": "t2", "v": 3}]
+}`
+
+func TestWithExcludedPaths(t *testing.T) {
+	patch, err := CreatePatchWithOptions([]byte(pathsBase), []byte(pathsModified),
+		WithExcludedPaths("/metadata/updatedAt", "/etag", "/status/*", "/items/*/lastSeen"))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{
+		NewPatch("add", "/items/0", map[string]interface{}{"id": 0.0, "v": 0.0}),
+		NewPatch("replace", "/items/2/v", 3.0),
+		NewPatch("replace", "/metadata/name", "y"),
+		NewPatch("replace", "/spec/replicas", 2.0),
+	}, patch)
+}
+
+func TestWithExcludedPathsKeepsReplaceDecision(t *testing.T) {
+	a := `{"a": {"b": 1, "c": 2, "seen": 1}}`
+	b := `{"a": {"b": 3, "c": 4, "seen": 2}}`
+	patch, err := CreatePatch([]byte(a), []byte(b))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(patch))
+
+	// the replace of /a would carry the excluded value, so the members are
+	// replaced one by one
+	patch, err = CreatePatchWithOptions([]byte(a), []byte(b), WithExcludedPaths("/*/seen"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a/b", "/a/c"}, Patch(patch).Paths())
+
+	// excluding values elsewhere does not change the decision
+	patch, err = CreatePatchWithOptions([]byte(a), []byte(b), WithExcludedPaths("/b/*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(patch))
+}
+
+func TestWithExcludedPathsInEmittedValues(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		patch []JSONPatchOperation
+	}{
+		{
+			`{}`, `{"metadata": {"updatedAt": "1", "name": "x"}}`,
+			[]JSONPatchOperation{NewPatch("add", "/metadata", map[string]interface{}{"name": "x"})},
+		},
+		{
+			`{"metadata": "none"}`, `{"metadata": {"updatedAt": "1", "name": "x"}}`,
+			[]JSONPatchOperation{NewPatch("replace", "/metadata", map[string]interface{}{"name": "x"})},
+		},
+		{
+			`{"items": [{"id": 1}]}`, `{"items": [{"id": 1}, {"id": 2, "lastSeen": "t"}]}`,
+			[]JSONPatchOperation{NewPatch("add", "/items/1", map[string]interface{}{"id": 2.0})},
+		},
+		{
+			`{}`, `{"items": [{"id": 2, "lastSeen": "t"}], "tags": ["a"]}`,
+			[]JSONPatchOperation{
+				NewPatch("add", "/items", []interface{}{map[string]interface{}{"id": 2.0}}),
+				NewPatch("add", "/tags", []interface{}{}),
+			},
+		},
+	}
+	for _, tc := range cases {
+		patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b),
+			WithExcludedPaths("/metadata/updatedAt", "/items/*/lastSeen", "/tags/*"), WithMinimisation(false))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.patch, patch, "%s -> %s", tc.a, tc.b)
+	}
+
+	// the documents themselves are left alone
+	b := map[string]interface{}{"metadata": map[string]interface{}{"updatedAt": "1"}}
+	_, err := newDiffer(WithExcludedPaths("/metadata/updatedAt")).createPatch(map[string]interface{}{}, b)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{"updatedAt": "1"}}, b)
+}
+
+func TestWithIncludedPaths(t *testing.T) {
+	patch, err := CreatePatchWithOptions([]byte(pathsBase), []byte(pathsModified),
+		WithIncludedPaths("/spec", "/items/*/v"))
+	assert.NoError(t, err)
+	// the inserted element shifts the others, the values are compared by index
+	assert.Equal(t, []JSONPatchOperation{
+		NewPatch("replace", "/items/0/v", 0.0),
+		NewPatch("replace", "/items/1/v", 1.0),
+		NewPatch("replace", "/spec/replicas", 2.0),
+	}, patch)
+
+	patch, err = CreatePatchWithOptions([]byte(pathsBase), []byte(pathsModified),
+		WithIncludedPaths("/spec", "/status"), WithExcludedPaths("/status/ready"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/spec/replicas", "/status/phase"}, Patch(patch).Paths())
+
+	patch, err = CreatePatchWithOptions([]byte(pathsBase), []byte(pathsModified), WithIncludedPaths(""))
+	assert.NoError(t, err)
+	all, err := CreatePatch([]byte(pathsBase), []byte(pathsModified))
+	assert.NoError(t, err)
+	assert.Equal(t, all, patch)
+}
+
+func TestWithIncludedPathsInChangedContainers(t *testing.T) {
+	cases := []struct {
+		a, b string
+	}{
+		{`{"spec": {"x": 1}}`, `{"spec": "none"}`},
+		{`{"spec": {"x": 1}}`, `{}`},
+		{`{}`, `{"spec": {"x": 1}}`},
+		{`{"spec": [{"x": 1}]}`, `{"spec": [{"x": 1}, {"x": 2}]}`},
+		{`{"spec": {"y": 1}}`, `{"spec": {"y": 2}}`},
+		{`[]`, `{"spec": {"x": 1}}`},
+	}
+	for _, tc := range cases {
+		patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithIncludedPaths("/spec/x", "/spec/*/x"))
+		assert.NoError(t, err)
+		assert.Empty(t, patch, "%s -> %s", tc.a, tc.b)
+	}
+}
+
+func TestPathFilterErrors(t *testing.T) {
+	_, err := CreatePatchWithOptions([]byte(simpleA), []byte(simpleB), WithExcludedPaths("a/*"))
+	assert.Error(t, err)
+	_, err = CreatePatchWithOptions([]byte(simpleA), []byte(simpleB), WithIncludedPaths("/a", "/b~"))
+	assert.Error(t, err)
+}
+
+func TestPathPatterns(t *testing.T) {
+	pp, err := parsePattern("/items/*/a~1b")
+	assert.NoError(t, err)
+	assert.True(t, pp.match(makePath(makePath("/items", 0), "a/b")))
+	assert.False(t, pp.match("/items/0/a"))
+	assert.False(t, pp.match("/items/0"))
+	assert.True(t, pp.matchPrefix("/items/0/a~1b/c"))
+	assert.False(t, pp.matchPrefix("/items/0/ab/c"))
+	assert.True(t, pp.matchBelow("/items/7"))
+	assert.True(t, pp.matchBelow(""))
+	assert.False(t, pp.matchBelow("/other/7"))
+	assert.False(t, pp.matchBelow("/items/0/a~1b"))
+
+	root, err := parsePattern("")
+	assert.NoError(t, err)
+	assert.True(t, root.match(""))
+	assert.True(t, root.matchPrefix("/x"))
+	assert.False(t, root.matchBelow(""))
+}
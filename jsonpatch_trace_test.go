@@ -0,0 +1,62 @@
+package jsonpatch
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracerReceivesDecisions(t *testing.T) {
+	a := `{"a":1, "b":"x", "list":[1, 2, 3], "gone":true}`
+	b := `{"a":"1", "b":"y", "list":[1, 3, 4], "new":{"c":1}}`
+	var events []TraceEvent
+	tracer := TracerFunc(func(e TraceEvent) {
+		events = append(events, e)
+	})
+	_, err := CreatePatchWithOptions([]byte(a), []byte(b), WithTracer(tracer), WithMinimisation(false))
+	assert.NoError(t, err)
+
+	decisions := map[string]string{}
+	for _, e := range events {
+		decisions[e.Path] += e.Differ + " " + e.Decision + ";"
+	}
+	assert.Equal(t, "object remove;", decisions["/gone"])
+	assert.Equal(t, "object add;", decisions["/new"])
+	assert.Equal(t, "object replace;", decisions["/a"])
+	assert.Equal(t, "value replace;", decisions["/b"])
+	assert.Equal(t, "array strategy;", decisions["/list"])
+	assert.Equal(t, "array remove;", decisions["/list/1"])
+	assert.Equal(t, "array add;", decisions["/list/2"])
+}
+
+func TestTracerFullReplace(t *testing.T) {
+	var events []TraceEvent
+	tracer := TracerFunc(func(e TraceEvent) {
+		events = append(events, e)
+	})
+	_, err := CreatePatchWithOptions([]byte(`{"a":[1, 2, 3]}`), []byte(`{"a":[4, 5, 6]}`), WithTracer(tracer))
+	assert.NoError(t, err)
+	last := events[len(events)-1]
+	assert.Equal(t, TraceFullReplace, last.Decision)
+	assert.Equal(t, "/a", last.Path)
+	assert.Contains(t, last.String(), `array full-replace "/a": replace costs`)
+}
+
+func TestNoOutputWithoutTracer(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	for _, strategy := range []ArrayStrategy{ArrayLCS, ArrayGreedy} {
+		_, err = CreatePatchWithOptions([]byte(arrayBase), []byte(arrayUpdated), WithArrayStrategy(strategy))
+		assert.NoError(t, err)
+	}
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, string(out))
+}
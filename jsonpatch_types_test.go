@@ -0,0 +1,86 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffNumberKinds(t *testing.T) {
+	cases := map[string]struct {
+		one, two interface{}
+	}{
+		"int":         {int(1), int(2)},
+		"int8":        {int8(1), int8(2)},
+		"int16":       {int16(1), int16(2)},
+		"int32":       {int32(1), int32(2)},
+		"int64":       {int64(1), int64(2)},
+		"uint":        {uint(1), uint(2)},
+		"uint8":       {uint8(1), uint8(2)},
+		"uint16":      {uint16(1), uint16(2)},
+		"uint32":      {uint32(1), uint32(2)},
+		"uint64":      {uint64(1), uint64(2)},
+		"uintptr":     {uintptr(1), uintptr(2)},
+		"float32":     {float32(1), float32(2)},
+		"float64":     {float64(1), float64(2)},
+		"json.Number": {json.Number("1"), json.Number("2")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := map[string]interface{}{"n": tc.one, "list": []interface{}{tc.one}}
+			b := map[string]interface{}{"n": tc.two, "list": []interface{}{tc.one, tc.two}}
+			patch, err := newDiffer(WithMinimisation(false)).createPatch(a, b)
+			assert.NoError(t, err)
+			if assert.Equal(t, 2, len(patch), "%v", patch) {
+				assert.Equal(t, NewPatch("add", "/list/1", tc.two), patch[0])
+				assert.Equal(t, NewPatch("replace", "/n", tc.two), patch[1])
+			}
+
+			// numbers are compared by value whatever their type
+			patch, err = newDiffer().createPatch(map[string]interface{}{"n": float64(1)}, map[string]interface{}{"n": tc.one})
+			assert.NoError(t, err)
+			assert.Empty(t, patch)
+		})
+	}
+}
+
+func TestDiffLargeIntegers(t *testing.T) {
+	a := map[string]interface{}{"id": uint64(math.MaxUint64), "n": json.Number("12345678901234567890123")}
+	b := map[string]interface{}{"id": uint64(math.MaxUint64 - 1), "n": json.Number("12345678901234567890124")}
+	patch, err := newDiffer(WithMinimisation(false)).createPatch(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(patch))
+
+	patch, err = newDiffer().createPatch(map[string]interface{}{"n": json.Number("1.0")}, map[string]interface{}{"n": json.Number("1e0")})
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestDiffUnsupportedTypes(t *testing.T) {
+	cases := map[string]struct {
+		value interface{}
+		path  string
+	}{
+		"channel":        {make(chan int), "/a/0"},
+		"struct":         {struct{}{}, "/a/0"},
+		"string slice":   {[]string{"x"}, "/a/0"},
+		"NaN":            {math.NaN(), "/a/0"},
+		"infinity":       {math.Inf(1), "/a/0"},
+		"invalid number": {json.Number("0x10"), "/a/0"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			doc := map[string]interface{}{"a": []interface{}{tc.value}}
+			for _, docs := range [][2]interface{}{{doc, map[string]interface{}{}}, {map[string]interface{}{}, doc}} {
+				var typeErr *UnsupportedTypeError
+				_, err := newDiffer().createPatch(docs[0], docs[1])
+				if assert.True(t, errors.As(err, &typeErr), "%v", err) {
+					assert.Equal(t, tc.path, typeErr.Path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnorderedArraysIgnoreOrder(t *testing.T) {
+	a := `{"tags":["a","b","c"], "list":[1,2]}`
+	b := `{"tags":["c","a","b"], "list":[2,1]}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithUnorderedArrays("/tags"), WithMinimisation(false))
+	assert.NoError(t, err)
+	for _, op := range patch {
+		assert.NotContains(t, op.Path, "/tags", "%v", op)
+	}
+	assert.NotEmpty(t, patch)
+}
+
+func TestUnorderedArraysMembership(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		patch []JSONPatchOperation
+	}{
+		{
+			`["a","b","c","b"]`, `["c","d","b","a"]`,
+			[]JSONPatchOperation{NewPatch("remove", "/3", nil), NewPatch("add", "/-", "d")},
+		},
+		{
+			`["a","b","c","d"]`, `["d","b"]`,
+			[]JSONPatchOperation{NewPatch("remove", "/2", nil), NewPatch("remove", "/0", nil)},
+		},
+		{
+			`["a"]`, `["b","a","c","a"]`,
+			[]JSONPatchOperation{NewPatch("add", "/-", "b"), NewPatch("add", "/-", "c"), NewPatch("add", "/-", "a")},
+		},
+		{
+			`[{"name":"x","grants":["r"]},{"name":"y"}]`, `[{"name":"y"},{"name":"x","grants":["r","w"]}]`,
+			[]JSONPatchOperation{NewPatch("remove", "/0", nil), NewPatch("add", "/-", map[string]interface{}{"name": "x", "grants": []interface{}{"r", "w"}})},
+		},
+		{`[]`, `[]`, []JSONPatchOperation{}},
+	}
+	for _, tc := range cases {
+		for i := 0; i < 2; i++ {
+			patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithUnorderedArrays(""), WithMinimisation(false))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.patch, patch, "%s -> %s", tc.a, tc.b)
+		}
+		patch, err := CreatePatchWithOptions([]byte(tc.a), []byte(tc.b), WithUnorderedArrays(""), WithMinimisation(false))
+		assert.NoError(t, err)
+		modified, err := Apply([]byte(tc.a), patch)
+		assert.NoError(t, err)
+		assert.Equal(t, sortedElements(t, tc.b), sortedElements(t, string(modified)))
+	}
+}
+
+// sortedElements returns the elements of the json array doc encoded as json
+// and sorted, so arrays can be compared as multisets.
+func sortedElements(t *testing.T, doc string) []string {
+	var elements []json.RawMessage
+	assert.NoError(t, json.Unmarshal([]byte(doc), &elements))
+	var s []string
+	for _, e := range elements {
+		var v interface{}
+		assert.NoError(t, json.Unmarshal(e, &v))
+		b, err := json.Marshal(v)
+		assert.NoError(t, err)
+		s = append(s, string(b))
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestUnorderedArraysWithPatterns(t *testing.T) {
+	a := `{"users":[{"name":"ann","roles":["admin","developer","operator","support"]},{"name":"bob","roles":["dev"]}]}`
+	b := `{"users":[{"name":"ann","roles":["support","developer","operator","ops"]},{"name":"bob","roles":["dev"]}]}`
+	patch, err := CreatePatchWithOptions([]byte(a), []byte(b), WithUnorderedArrays("/users/*/roles"), WithTestOperations())
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{
+		NewPatch("test", "/users/0/roles/0", "admin"),
+		NewPatch("remove", "/users/0/roles/0", nil),
+		NewPatch("add", "/users/0/roles/-", "ops"),
+	}, patch)
+
+	// replacing the whole array is still preferred when it is shorter
+	patch, err = CreatePatchWithOptions([]byte(`{"tags":["a","b"]}`), []byte(`{"tags":["c","d"]}`), WithUnorderedArrays("/tags"))
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{NewPatch("replace", "/tags", []interface{}{"c", "d"})}, patch)
+
+	_, err = CreatePatchWithOptions([]byte(a), []byte(b), WithUnorderedArrays("users"))
+	assert.Error(t, err)
+}
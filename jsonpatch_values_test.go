@@ -0,0 +1,193 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type valuesAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city,omitempty"`
+}
+
+type valuesAudit struct {
+	Created time.Time  `json:"created"`
+	Deleted *time.Time `json:"deleted,omitempty"`
+	Version int        `json:"version,string"`
+}
+
+type valuesKey struct {
+	major, minor int
+}
+
+func (k valuesKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%d", k.major, k.minor)), nil
+}
+
+type valuesInner struct {
+	Name  string `json:"name"`
+	Extra string
+}
+
+type valuesUser struct {
+	valuesAudit
+	*valuesInner
+	ID       uint64                    `json:"id"`
+	Name     string                    `json:"name"` // shadows the embedded name
+	Email    string                    `json:"email,omitempty"`
+	Password string                    `json:"-"`
+	Dash     string                    `json:"-,"`
+	Tags     []string                  `json:"tags"`
+	Avatar   []byte                    `json:"avatar,omitempty"`
+	Address  *valuesAddress            `json:"address"`
+	Scores   map[int]float32           `json:"scores"`
+	Hosts    map[string]net.IP         `json:"hosts"`
+	Attrs    map[string]interface{}    `json:"attrs"`
+	Raw      json.RawMessage           `json:"raw,omitempty"`
+	Nested   [][]valuesAddress         `json:"nested"`
+	Flags    map[valuesKey]bool        `json:"flags,omitempty"`
+	Any      interface{}               `json:"any"`
+	Fixed    [2]int8                   `json:"fixed"`
+	Amount   json.Number               `json:"amount,omitempty"`
+	Children map[string]*valuesAddress `json:"children,omitempty"`
+	internal string
+}
+
+func TestCreatePatchFromValues(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	a := valuesUser{
+		valuesAudit: valuesAudit{Created: created, Version: 1},
+		ID:          1 << 60,
+		Name:        "alice",
+		Password:    "secret",
+		Tags:        []string{"a", "b"},
+		Address:     &valuesAddress{Street: "Main"},
+		Scores:      map[int]float32{1: 0.5},
+		Hosts:       map[string]net.IP{"lo": net.IPv4(127, 0, 0, 1)},
+		Attrs:       map[string]interface{}{"x": 1, "y": []interface{}{true}},
+		Nested:      [][]valuesAddress{{{Street: "A"}}},
+		Any:         int16(3),
+		internal:    "x",
+	}
+	b := valuesUser{
+		valuesAudit: valuesAudit{Created: created, Deleted: &deleted, Version: 2},
+		valuesInner: &valuesInner{Name: "ignored", Extra: "extra"},
+		ID:          1<<60 + 1,
+		Name:        "bob",
+		Email:       "bob@example.com",
+		Password:    "other",
+		Dash:        "dash",
+		Tags:        []string{"b", "c"},
+		Avatar:      []byte{1, 2, 3},
+		Address:     &valuesAddress{Street: "Main", City: "Springfield"},
+		Scores:      map[int]float32{1: 0.25, 2: 1},
+		Hosts:       map[string]net.IP{"lo": net.IPv6loopback},
+		Attrs:       map[string]interface{}{"x": 1.5, "y": []interface{}{false}},
+		Raw:         json.RawMessage(`{"z":[1, 2.50]}`),
+		Nested:      [][]valuesAddress{{{Street: "A"}, {Street: "B"}}, nil},
+		Flags:       map[valuesKey]bool{{1, 2}: true},
+		Any:         map[string]int{"k": 1},
+		Fixed:       [2]int8{0, 1},
+		Amount:      "12345678901234567890.5",
+		Children:    map[string]*valuesAddress{"c": nil},
+		internal:    "y",
+	}
+
+	for _, opts := range [][]Option{nil, {WithMinimisation(false)}} {
+		patch, err := CreatePatchFromValues(a, b, opts...)
+		assert.NoError(t, err)
+		patchJSON, err := json.Marshal(patch)
+		assert.NoError(t, err)
+
+		aJSON, err := json.Marshal(a)
+		assert.NoError(t, err)
+		bJSON, err := json.Marshal(b)
+		assert.NoError(t, err)
+		expected, err := CreatePatchWithOptions(aJSON, bJSON, append(opts, WithNumberMode(NumberExact))...)
+		assert.NoError(t, err)
+		expectedJSON, err := json.Marshal(expected)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expectedJSON), string(patchJSON))
+
+		modified, err := Apply(aJSON, patch)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(bJSON), string(modified))
+	}
+
+	// pointers and values are the same
+	patch, err := CreatePatchFromValues(&a, a)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestCreatePatchFromValuesErrors(t *testing.T) {
+	var typeErr *UnsupportedTypeError
+	_, err := CreatePatchFromValues(map[string]interface{}{}, map[string]interface{}{"f": []interface{}{func() {}}})
+	if assert.True(t, errors.As(err, &typeErr), "%v", err) {
+		assert.Equal(t, "/f/0", typeErr.Path)
+	}
+	_, err = CreatePatchFromValues(struct{ C complex128 }{}, nil)
+	if assert.True(t, errors.As(err, &typeErr), "%v", err) {
+		assert.Equal(t, "/C", typeErr.Path)
+	}
+
+	type cyclic struct {
+		Next *cyclic
+	}
+	c := &cyclic{}
+	c.Next = c
+	_, err = CreatePatchFromValues(c, nil)
+	assert.Error(t, err)
+
+	// paths are escaped, also when the error comes from a method
+	_, err = CreatePatchFromValues(map[string][]valuesFailing{"a/b": {{}}}, nil)
+	assert.ErrorIs(t, err, errValuesFailing)
+	assert.Contains(t, err.Error(), `"/a~1b/0"`)
+}
+
+var errValuesFailing = errors.New("failing")
+
+type valuesFailing struct{}
+
+func (valuesFailing) MarshalJSON() ([]byte, error) {
+	return nil, errValuesFailing
+}
+
+// benchmarkValues returns two versions of a list of addresses for the benchmarks
+// comparing CreatePatchFromValues with marshalling the values first.
+func benchmarkValues() (a, b []valuesAddress) {
+	for i := 0; i < 200; i++ {
+		a = append(a, valuesAddress{Street: fmt.Sprintf("street %d", i), City: "city"})
+	}
+	b = append(b, a...)
+	b[10].City = "town"
+	b = append(b[:50], b[51:]...)
+	return a, b
+}
+
+func BenchmarkCreatePatchFromValues(b *testing.B) {
+	v1, v2 := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		if _, err := CreatePatchFromValues(v1, v2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreatePatchFromMarshalledValues(b *testing.B) {
+	v1, v2 := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		a, _ := json.Marshal(v1)
+		m, _ := json.Marshal(v2)
+		if _, err := CreatePatch(a, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// CreateMergePatch creates a JSON Merge Patch as specified in RFC 7386, the
+// format of application/merge-patch+json, turning the json document a into b.
+//
+// A merge patch removes object members by setting them to null, so it cannot
+// set a member to null. An error is returned if b has such a member where a
+// does not.
+func CreateMergePatch(a, b []byte) ([]byte, error) {
+	aI, bI, err := decodeDocuments(a, b, true)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := createMergePatch(aI, bI, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(patch)
+}
+
+func createMergePatch(a, b interface{}, path string) (interface{}, error) {
+	bt, ok := b.(map[string]interface{})
+	if !ok {
+		// anything but an object replaces the target as a whole
+		return b, nil
+	}
+	at, ok := a.(map[string]interface{})
+	if !ok {
+		// a target that is not an object is replaced by an empty one first
+		at = map[string]interface{}{}
+	}
+	patch := map[string]interface{}{}
+	for _, key := range sortedKeys(at) {
+		if _, ok := bt[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for _, key := range sortedKeys(bt) {
+		bv := bt[key]
+		av, ok := at[key]
+		if ok && reflect.DeepEqual(av, bv) {
+			continue
+		}
+		p := makePath(path, key)
+		if bv == nil {
+			return nil, fmt.Errorf("merge patch cannot set %q to null", p)
+		}
+		v, err := createMergePatch(av, bv, p)
+		if err != nil {
+			return nil, err
+		}
+		patch[key] = v
+	}
+	return patch, nil
+}
+
+// ApplyMergePatch applies the JSON Merge Patch patch, as specified in RFC 7386,
+// to the json document doc and returns the json encoded result.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	d, err := decodeDocument(doc, "original", true)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeDocument(patch, "patch", true)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(applyMergePatch(d, p))
+}
+
+func applyMergePatch(target, patch interface{}) interface{} {
+	pt, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	tt, ok := target.(map[string]interface{})
+	if !ok {
+		tt = map[string]interface{}{}
+	}
+	for key, pv := range pt {
+		if pv == nil {
+			delete(tt, key)
+			continue
+		}
+		tt[key] = applyMergePatch(tt[key], pv)
+	}
+	return tt
+}
+
+// ConvertToMergePatch returns the JSON Merge Patch having the same effect on
+// the json document doc as patch.
+//
+// Not every patch can be expressed as a merge patch. An error is returned if
+// patch contains test operations, changes single array elements instead of
+// replacing whole arrays, or sets an object member to null. doc is needed to
+// tell array elements from object members.
+func ConvertToMergePatch(doc []byte, patch []JSONPatchOperation) ([]byte, error) {
+	original, err := decodeDocument(doc, "original", true)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := normaliseValue(original)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range patch {
+		if op.Operation == "test" {
+			return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: errors.New("test operations cannot be expressed in a merge patch")}
+		}
+		paths := []string{op.Path}
+		if op.Operation == "move" || op.Operation == "copy" {
+			paths = append(paths, op.From)
+		}
+		for _, path := range paths {
+			p, err := pointer.Parse(path)
+			if err != nil {
+				return nil, operationError(i, op, err)
+			}
+			if inArray(modified, p) {
+				return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: fmt.Errorf("array element %q cannot be changed by a merge patch", path)}
+			}
+		}
+		modified, err = applyOperation(modified, op)
+		if err != nil {
+			return nil, operationError(i, op, err)
+		}
+	}
+	mergePatch, err := createMergePatch(original, modified, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch)
+}
+
+// inArray reports whether p passes through an array in doc.
+func inArray(doc interface{}, p pointer.Pointer) bool {
+	for _, token := range p {
+		switch node := doc.(type) {
+		case []interface{}:
+			return true
+		case map[string]interface{}:
+			doc = node[token]
+		default:
+			return false
+		}
+	}
+	return false
+}
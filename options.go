@@ -0,0 +1,464 @@
+package jsonpatch
+
+import (
+	"reflect"
+)
+
+// ArrayStrategy selects the algorithm used to compare arrays.
+type ArrayStrategy int
+
+const (
+	// ArrayLCS computes the longest common subsequence of both arrays with
+	// Myers' algorithm and emits the minimal sequence of adds and removes.
+	// Objects and arrays changed in place are diffed recursively.
+	ArrayLCS ArrayStrategy = iota
+	// ArrayGreedy matches elements with a single greedy scan. It is kept for
+	// compatibility with patches created by earlier versions.
+	ArrayGreedy
+)
+
+// NumberMode selects how numbers in the documents are decoded and compared.
+type NumberMode int
+
+const (
+	// NumberFloat64 decodes numbers as float64, like json.Unmarshal. Integers
+	// beyond 2^53 lose precision and may compare equal to others.
+	NumberFloat64 NumberMode = iota
+	// NumberExact decodes numbers as json.Number and compares them by their
+	// exact value, so 1.0 and 1 are equal. Values in the patch keep the
+	// representation they have in the modified document.
+	NumberExact
+	// NumberLexical decodes numbers like NumberExact but compares them by
+	// their representation, so 1.0 and 1 are different.
+	NumberLexical
+)
+
+type options struct {
+	arrayStrategy    ArrayStrategy
+	numberMode       NumberMode
+	arrayKeys        []arrayKey
+	unordered        []pathPattern
+	minimise         bool
+	endOfArray       bool
+	cost             CostFunc
+	costTiesReplace  bool
+	replaceThreshold float64
+	testOps          bool
+	parentTests      bool
+	oldValues        bool
+	excluded         []pathPattern
+	included         []pathPattern
+	equalers         []pathEqualer
+	floatTolerance   float64
+	tracer           Tracer
+	// err is the first error found in an option, returned by CreatePatchWithOptions
+	err error
+}
+
+type arrayKey struct {
+	pattern pathPattern
+	key     string
+}
+
+func (o *options) setErr(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// Option configures CreatePatchWithOptions.
+type Option func(*options)
+
+// WithArrayStrategy selects the algorithm used to compare arrays, ArrayLCS by
+// default.
+func WithArrayStrategy(s ArrayStrategy) Option {
+	return func(o *options) {
+		o.arrayStrategy = s
+	}
+}
+
+// WithArrayKey declares that elements of the arrays matched by the path
+// pattern, like "/orders/*/items", are objects identified by their member
+// key, like "id".
+//
+// Elements with the same identity are diffed recursively wherever they are in
+// the array, so only elements that were really inserted or deleted are added
+// or removed, and elements that changed position are moved. Elements without
+// the key are matched by their whole value.
+func WithArrayKey(pattern, key string) Option {
+	return func(o *options) {
+		pp, err := parsePattern(pattern)
+		if err != nil {
+			o.setErr(err)
+			return
+		}
+		o.arrayKeys = append(o.arrayKeys, arrayKey{pattern: pp, key: key})
+	}
+}
+
+// WithUnorderedArrays declares that the order of the elements of the arrays
+// matched by the given patterns is irrelevant, like for tags or permission
+// sets. See the package documentation for the syntax of patterns.
+//
+// Only membership changes produce operations: elements missing from the
+// modified array are removed by their index, from the last one to the first,
+// and new elements are then added to the end with the "-" reference token,
+// in the order of the modified array. Elements are matched by value, an
+// element occurring more often in the modified array is added again. As the
+// length of the arrays is not recorded, such patches cannot be inverted.
+func WithUnorderedArrays(patterns ...string) Option {
+	return func(o *options) {
+		for _, pattern := range patterns {
+			pp, err := parsePattern(pattern)
+			if err != nil {
+				o.setErr(err)
+				return
+			}
+			o.unordered = append(o.unordered, pp)
+		}
+	}
+}
+
+// WithMinimisation controls whether changes to an object or array are
+// collapsed into a single replace of the whole value when that is shorter than
+// the individual operations. It is enabled by default.
+func WithMinimisation(enabled bool) Option {
+	return func(o *options) {
+		o.minimise = enabled
+	}
+}
+
+// WithEndOfArrayAdds makes elements added to the end of an array be added
+// with the "-" reference token, like "/a/-", instead of their index, so the
+// patch does not assume the length of the array, which may have grown
+// concurrently. Such patches cannot be inverted.
+func WithEndOfArrayAdds() Option {
+	return func(o *options) {
+		o.endOfArray = true
+	}
+}
+
+// WithTestOperations precedes every replace and remove operation with a test
+// operation asserting the value being replaced or removed, so the patch fails
+// when applied to a document that changed in the meantime.
+func WithTestOperations() Option {
+	return func(o *options) {
+		o.testOps = true
+	}
+}
+
+// WithParentTestOperations prepends test operations asserting the original
+// value of the objects and arrays containing the changes, so the patch fails
+// when applied to a document that changed anywhere near them. Only the
+// outermost of nested containers is tested.
+func WithParentTestOperations() Option {
+	return func(o *options) {
+		o.parentTests = true
+	}
+}
+
+// WithOldValues records the value each replace and remove operation
+// overwrites in its OldValue, as needed by Invert.
+func WithOldValues() Option {
+	return func(o *options) {
+		o.oldValues = true
+	}
+}
+
+// WithIgnoredPaths is WithExcludedPaths under its earlier name. The paths are
+// path patterns, a JSON Pointer without "*" tokens excludes a single value.
+func WithIgnoredPaths(paths ...string) Option {
+	return WithExcludedPaths(paths...)
+}
+
+// WithExcludedPaths excludes the values at and below the paths matched by the
+// given path patterns, like "/items/*/lastSeen" or "/status/*", from the
+// comparison, no operations are emitted for them. They are also left out of
+// the values of added and replaced objects and arrays.
+//
+// Excluded values are left out before deciding whether the changes to an
+// object or array are collapsed into a replace, which is never done for
+// values containing excluded ones, and array elements differing only in
+// excluded values are considered equal.
+func WithExcludedPaths(patterns ...string) Option {
+	return func(o *options) {
+		for _, pattern := range patterns {
+			pp, err := parsePattern(pattern)
+			if err != nil {
+				o.setErr(err)
+				return
+			}
+			o.excluded = append(o.excluded, pp)
+		}
+	}
+}
+
+// WithIncludedPaths restricts the comparison to the values at and below the
+// paths matched by the given path patterns, see the package documentation.
+// Objects and arrays containing included values are only compared member by
+// member and element by element at the same index: when they are added,
+// removed or replaced by a value of another type, nothing is emitted, as
+// that would change values that are not included.
+func WithIncludedPaths(patterns ...string) Option {
+	return func(o *options) {
+		for _, pattern := range patterns {
+			pp, err := parsePattern(pattern)
+			if err != nil {
+				o.setErr(err)
+				return
+			}
+			o.included = append(o.included, pp)
+		}
+	}
+}
+
+// WithFloatTolerance makes numbers that differ by no more than tolerance
+// compare as equal.
+func WithFloatTolerance(tolerance float64) Option {
+	return func(o *options) {
+		o.floatTolerance = tolerance
+	}
+}
+
+// WithNumberMode selects how numbers are decoded and compared, NumberFloat64
+// by default.
+func WithNumberMode(m NumberMode) Option {
+	return func(o *options) {
+		o.numberMode = m
+	}
+}
+
+// WithTracer reports every decision made while comparing objects and arrays
+// to t, which helps to understand why a patch looks the way it does. Nothing
+// is traced by default.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// isUnordered reports whether the order of the elements of the array at path
+// is irrelevant.
+func (o *options) isUnordered(path string) bool {
+	for _, pp := range o.unordered {
+		if pp.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayKey returns the key identifying elements of the array at path, or
+// false if there is none.
+func (o *options) arrayKey(path string) (string, bool) {
+	for _, ak := range o.arrayKeys {
+		if ak.pattern.match(path) {
+			return ak.key, true
+		}
+	}
+	return "", false
+}
+
+// differ holds the configuration of a single CreatePatchWithOptions call.
+type differ struct {
+	options
+}
+
+func newDiffer(opts ...Option) *differ {
+	d := &differ{options: options{minimise: true, cost: CostBytes, costTiesReplace: true, replaceThreshold: 1}}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	return d
+}
+
+// filtersPaths reports whether some values are excluded from the comparison.
+func (o *options) filtersPaths() bool {
+	return len(o.excluded) > 0 || len(o.included) > 0
+}
+
+// isIgnored reports whether the value at path is excluded from the comparison.
+func (o *options) isIgnored(path string) bool {
+	if !o.filtersPaths() {
+		return false
+	}
+	for _, pp := range o.excluded {
+		if pp.matchPrefix(path) {
+			return true
+		}
+	}
+	if len(o.included) == 0 {
+		return false
+	}
+	for _, pp := range o.included {
+		if pp.matchPrefix(path) || pp.matchBelow(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPartial reports whether only some of the values below path are included
+// in the comparison, so the value at path must not be added, removed or
+// replaced as a whole.
+func (o *options) isPartial(path string) bool {
+	if len(o.included) == 0 {
+		return false
+	}
+	for _, pp := range o.included {
+		if pp.matchPrefix(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoresBelow reports whether some value below path is excluded from the
+// comparison, in which case the value at path must not be replaced as a whole.
+func (o *options) ignoresBelow(path string) bool {
+	if !o.filtersPaths() {
+		return false
+	}
+	for _, pp := range o.excluded {
+		if pp.matchBelow(path) {
+			return true
+		}
+	}
+	return o.isPartial(path)
+}
+
+// withoutExcluded returns value, emitted at path, without the members and
+// elements excluded from the comparison. value is copied rather than modified
+// if anything is left out.
+func (o *options) withoutExcluded(value interface{}, path string) interface{} {
+	for _, pp := range o.excluded {
+		if pp.matchBelow(path) {
+			return o.stripExcluded(value, path)
+		}
+	}
+	return value
+}
+
+func (o *options) stripExcluded(value interface{}, path string) interface{} {
+	switch vt := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vt))
+		for key, e := range vt {
+			p := makePath(path, key)
+			if !o.isExcluded(p) {
+				m[key] = o.withoutExcluded(e, p)
+			}
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, 0, len(vt))
+		for i, e := range vt {
+			p := makePath(path, i)
+			if !o.isExcluded(p) {
+				s = append(s, o.withoutExcluded(e, p))
+			}
+		}
+		return s
+	}
+	return value
+}
+
+// isExcluded reports whether path is matched by a pattern of
+// WithExcludedPaths.
+func (o *options) isExcluded(path string) bool {
+	for _, pp := range o.excluded {
+		if pp.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal reports whether a and b are the same. Numbers are compared by value
+// whatever their Go type, taking the float tolerance into account.
+func (o *options) equal(a, b interface{}) bool {
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for key, av := range at {
+			bv, ok := bt[key]
+			if !ok || !o.equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !o.equal(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if kindOf(a) == kindNumber {
+		return kindOf(b) == kindNumber && o.numbersEqual(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// equalElements is equalAt for the elements at index i of the arrays at path,
+// whose path is only built when it is needed.
+func (o *options) equalElements(a, b interface{}, path string, i int) bool {
+	if !o.filtersPaths() && len(o.equalers) == 0 {
+		return o.equal(a, b)
+	}
+	return o.equalAt(a, b, makePath(path, i))
+}
+
+// equalAt is like equal for the values at path, skipping the values below it
+// that are excluded from the comparison and using the configured Equalers.
+func (o *options) equalAt(a, b interface{}, path string) bool {
+	if !o.filtersPaths() && len(o.equalers) == 0 {
+		return o.equal(a, b)
+	}
+	if o.isIgnored(path) || o.equalByEqualer(a, b, path) {
+		return true
+	}
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, av := range at {
+			p := makePath(path, key)
+			if o.isIgnored(p) {
+				continue
+			}
+			bv, ok := bt[key]
+			if !ok || !o.equalAt(av, bv, p) {
+				return false
+			}
+		}
+		for key := range bt {
+			if _, ok := at[key]; !ok && !o.isIgnored(makePath(path, key)) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !o.equalElements(at[i], bt[i], path, i) {
+				return false
+			}
+		}
+		return true
+	}
+	return o.equal(a, b)
+}
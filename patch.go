@@ -0,0 +1,277 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// Len returns the number of operations in p.
+func (p Patch) Len() int {
+	return len(p)
+}
+
+// Apply applies p to the json encoded document doc, see Apply.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	return Apply(doc, p)
+}
+
+// Invert returns the patch undoing p, see Invert.
+func (p Patch) Invert() (Patch, error) {
+	return Invert(p)
+}
+
+// Validate checks that every operation of p is well formed: its op is one of
+// those defined by RFC 6902, its path and from are valid JSON Pointers and a
+// move does not move a value into one of its own children. The returned error
+// is an *InvalidOperationError for the first operation that is not.
+func (p Patch) Validate() error {
+	for i, op := range p {
+		if err := validateOperation(op); err != nil {
+			return &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: err}
+		}
+	}
+	return nil
+}
+
+func validateOperation(op JSONPatchOperation) error {
+	path, err := pointer.Parse(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Operation {
+	case "add", "remove", "replace", "test":
+		return nil
+	case "move", "copy":
+		from, err := pointer.Parse(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Operation == "move" && len(path) > len(from) && path.HasPrefix(from) {
+			return fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		return nil
+	case "":
+		return errors.New("missing operation")
+	}
+	return fmt.Errorf("unknown operation %q", op.Operation)
+}
+
+// Paths returns the path of every operation of p, in order.
+func (p Patch) Paths() []string {
+	paths := make([]string, len(p))
+	for i, op := range p {
+		paths[i] = op.Path
+	}
+	return paths
+}
+
+// Filter returns the operations of p for which keep returns true.
+func (p Patch) Filter(keep func(op JSONPatchOperation) bool) Patch {
+	filtered := Patch{}
+	for _, op := range p {
+		if keep(op) {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// MarshalJSON encodes p as a JSON Patch document, the format of
+// application/json-patch+json. An empty patch is encoded as [].
+func (p Patch) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]JSONPatchOperation(p))
+}
+
+// An Encoder writes patch documents to an output stream.
+type Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes patch to the stream as a JSON Patch document followed by a
+// newline. Operations are encoded and written one at a time, so the encoding
+// of a large patch is never held in memory as a whole; if an operation cannot
+// be encoded, those before it have already been written.
+func (e *Encoder) Encode(patch []JSONPatchOperation) error {
+	e.buf.Reset()
+	e.buf.WriteByte('[')
+	for i := range patch {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := patch[i].encode(&e.buf); err != nil {
+			return err
+		}
+		if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+			return err
+		}
+		e.buf.Reset()
+	}
+	e.buf.WriteString("]\n")
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+// UnmarshalJSON decodes a JSON Patch document, the format of
+// application/json-patch+json, as strictly as DecodePatch.
+func (p *Patch) UnmarshalJSON(data []byte) error {
+	ops, err := DecodePatch(data)
+	if err != nil {
+		return err
+	}
+	*p = ops
+	return nil
+}
+
+// DecodePatch decodes the JSON Patch document data. Unlike decoding into a
+// []JSONPatchOperation, every operation is checked to be valid before it is
+// applied: the op must be one defined by RFC 6902, the members it needs must
+// be present and its path and from must be valid JSON Pointers. Operations
+// with the same member twice are rejected, as there is no telling which one
+// was meant. Other members are ignored. Numbers in values are decoded as
+// json.Number, keeping their exact value.
+//
+// An *InvalidDocumentError is returned if data is not a json array, an
+// *InvalidOperationError holding the index of the operation otherwise.
+func DecodePatch(data []byte) (Patch, error) {
+	var raw []json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err == nil && raw == nil {
+		err = errors.New("a patch document must be an array")
+	}
+	if err != nil {
+		return nil, documentError("patch", err)
+	}
+	patch := make(Patch, len(raw))
+	for i, r := range raw {
+		op, err := decodeOperation(r, true)
+		if err != nil {
+			return nil, &InvalidOperationError{Index: i, Operation: op.Operation, Path: op.Path, Cause: err}
+		}
+		patch[i] = op
+	}
+	return patch, nil
+}
+
+// decodeOperation decodes a single operation, checking that the members it
+// needs are present. If strict is set, members given twice are rejected, the
+// operation is validated and numbers in its value are kept exact as by
+// DecodePatch, otherwise they are decoded as float64. On error, the members
+// decoded so far are returned to describe the operation.
+func decodeOperation(data []byte, strict bool) (JSONPatchOperation, error) {
+	var op JSONPatchOperation
+	var members map[string]json.RawMessage
+	var err error
+	if strict {
+		members, err = decodeMembers(data)
+	} else {
+		err = json.Unmarshal(data, &members)
+	}
+	if err != nil {
+		return op, err
+	}
+	err = unmarshalMember(members, "op", &op.Operation)
+	if err != nil {
+		return op, err
+	}
+	err = unmarshalMember(members, "path", &op.Path)
+	if err != nil {
+		return op, err
+	}
+	switch op.Operation {
+	case "move", "copy":
+		err = unmarshalMember(members, "from", &op.From)
+		if err != nil {
+			return op, err
+		}
+	case "add", "replace", "test":
+		v, ok := members["value"]
+		if !ok {
+			return op, errors.New("missing 'value' member")
+		}
+		if strict {
+			err = unmarshalUseNumber(v, &op.Value)
+		} else {
+			err = json.Unmarshal(v, &op.Value)
+		}
+		if err != nil {
+			return op, err
+		}
+	}
+	if strict {
+		return op, validateOperation(op)
+	}
+	return op, nil
+}
+
+// decodeMembers decodes the members of the json object data, failing if a
+// member appears more than once.
+func decodeMembers(data []byte) (map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t != json.Delim('{') {
+		return nil, errors.New("an operation must be a json object")
+	}
+	members := map[string]json.RawMessage{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := t.(string)
+		if _, ok := members[name]; ok {
+			return nil, fmt.Errorf("duplicate '%s' member", name)
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		members[name] = v
+	}
+	return members, nil
+}
+
+// String formats p for display, one operation per line, like
+//
+//	replace /name "bob"
+//	move /tags/2 -> /tags/0
+func (p Patch) String() string {
+	var b strings.Builder
+	for i, op := range p {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(op.Operation)
+		b.WriteByte(' ')
+		switch op.Operation {
+		case "move", "copy":
+			fmt.Fprintf(&b, "%s -> %s", op.From, op.Path)
+		case "remove":
+			b.WriteString(op.Path)
+		default:
+			v, err := json.Marshal(op.Value)
+			if err != nil {
+				v = []byte(fmt.Sprintf("%v", op.Value))
+			}
+			fmt.Fprintf(&b, "%s %s", op.Path, v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+// Package jsonpatch creates and applies JSON Patches as defined by RFC 6902.
+//
+// # Path patterns
+//
+// Options applying to some values only select them by path patterns. A
+// pattern is a JSON Pointer in which the reference token "*" matches any
+// single token, e.g. "/items/*/tags" matches "/items/0/tags" and
+// "/items/1/tags", but not "/items/0/a/tags". "" matches the whole document.
+package jsonpatch
+
+import (
+	"strings"
+
+	"github.com/herkyl/jsonpatch/pointer"
+)
+
+// pathPattern is a parsed path pattern, see the package documentation. It
+// holds the escaped reference tokens, so paths built by makePath are matched
+// without parsing them.
+type pathPattern []string
+
+func parsePattern(s string) (pathPattern, error) {
+	p, err := pointer.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	pp := make(pathPattern, len(p))
+	for i, token := range p {
+		pp[i] = pointer.Escape(token)
+	}
+	return pp, nil
+}
+
+// compare returns the number of leading tokens of path, a JSON Pointer built
+// by makePath, that the pattern matches, and the number of tokens of path.
+func (pp pathPattern) compare(path string) (matched, tokens int) {
+	mismatch := false
+	for path != "" {
+		token := path[1:]
+		path = ""
+		if i := strings.IndexByte(token, '/'); i >= 0 {
+			token, path = token[:i], token[i:]
+		}
+		if !mismatch && tokens < len(pp) && (pp[tokens] == "*" || pp[tokens] == token) {
+			matched++
+		} else {
+			mismatch = true
+		}
+		tokens++
+	}
+	return matched, tokens
+}
+
+// match reports whether path is matched by the pattern.
+func (pp pathPattern) match(path string) bool {
+	matched, tokens := pp.compare(path)
+	return matched == len(pp) && tokens == len(pp)
+}
+
+// matchPrefix reports whether the pattern matches path or one of its parents.
+func (pp pathPattern) matchPrefix(path string) bool {
+	matched, _ := pp.compare(path)
+	return matched == len(pp)
+}
+
+// matchBelow reports whether the pattern matches values below path.
+func (pp pathPattern) matchBelow(path string) bool {
+	matched, tokens := pp.compare(path)
+	return tokens < len(pp) && matched == tokens
+}
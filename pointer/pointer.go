@@ -0,0 +1,197 @@
+// Package pointer implements JSON Pointers as specified in RFC 6901.
+//
+// A pointer such as "/foo/0/a~1b" is a sequence of reference tokens, here
+// "foo", "0" and "a/b". Parse decodes a pointer into its tokens, Format encodes
+// tokens back into a pointer and Eval resolves a pointer against a document
+// decoded by encoding/json.
+package pointer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrSyntax is returned for pointers that are neither empty nor start with '/'.
+	ErrSyntax = errors.New("pointer must be empty or start with '/'")
+	// ErrInvalidEscape is returned for reference tokens holding a '~' that is
+	// not followed by '0' or '1'.
+	ErrInvalidEscape = errors.New("'~' must be followed by '0' or '1'")
+	// ErrKeyNotFound is returned when an object has no member named by the token.
+	ErrKeyNotFound = errors.New("object has no such member")
+	// ErrIndexOutOfRange is returned when an array index is beyond its last element.
+	ErrIndexOutOfRange = errors.New("array index out of range")
+	// ErrEndOfArray is returned when "-", the element after the last one, is
+	// used to look up an existing value.
+	ErrEndOfArray = errors.New("'-' refers to a nonexistent array element")
+	// ErrInvalidIndex is returned for array indices that are not a decimal
+	// number or have leading zeros.
+	ErrInvalidIndex = errors.New("invalid array index")
+	// ErrNotContainer is returned when a token is applied to a scalar value.
+	ErrNotContainer = errors.New("value is neither an object nor an array")
+)
+
+// Error describes why a pointer could not be parsed or evaluated.
+type Error struct {
+	// Pointer is the pointer being parsed or evaluated.
+	Pointer string
+	// Token is the position of the offending reference token, or -1 if the
+	// error concerns the pointer as a whole.
+	Token int
+	// Err is one of the errors defined in this package.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Token < 0 {
+		return fmt.Sprintf("pointer %q: %v", e.Pointer, e.Err)
+	}
+	return fmt.Sprintf("pointer %q, token %d: %v", e.Pointer, e.Token, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var (
+	encoder = strings.NewReplacer("~", "~0", "/", "~1")
+	// Evaluation of each reference token begins by decoding any escaped
+	// character sequence. This is performed by first transforming any
+	// occurrence of the sequence '~1' to '/', and then transforming any
+	// occurrence of the sequence '~0' to '~'.
+	decoder = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Escape encodes a single reference token.
+func Escape(token string) string {
+	return encoder.Replace(token)
+}
+
+// Unescape decodes a single reference token.
+func Unescape(token string) string {
+	return decoder.Replace(token)
+}
+
+// Pointer is a parsed JSON Pointer, the empty Pointer refers to the whole
+// document.
+type Pointer []string
+
+// Parse decodes s into its reference tokens.
+func Parse(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, &Error{Pointer: s, Token: -1, Err: ErrSyntax}
+	}
+	p := Pointer(strings.Split(s[1:], "/"))
+	for i, token := range p {
+		if !validEscapes(token) {
+			return nil, &Error{Pointer: s, Token: i, Err: ErrInvalidEscape}
+		}
+		p[i] = Unescape(token)
+	}
+	return p, nil
+}
+
+func validEscapes(token string) bool {
+	for i := strings.IndexByte(token, '~'); i >= 0; i = strings.IndexByte(token, '~') {
+		if i+1 == len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return false
+		}
+		token = token[i+2:]
+	}
+	return true
+}
+
+// Format encodes tokens into a JSON Pointer.
+func Format(tokens ...string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(Escape(token))
+	}
+	return b.String()
+}
+
+// String returns the encoded form of p.
+func (p Pointer) String() string {
+	return Format(p...)
+}
+
+// Append returns a new Pointer referring to tokens below p.
+func (p Pointer) Append(tokens ...string) Pointer {
+	q := make(Pointer, 0, len(p)+len(tokens))
+	return append(append(q, p...), tokens...)
+}
+
+// Parent returns the pointer to the value containing the one p refers to,
+// and the last reference token. It returns false for the empty pointer.
+func (p Pointer) Parent() (Pointer, string, bool) {
+	if len(p) == 0 {
+		return nil, "", false
+	}
+	return p[:len(p)-1], p[len(p)-1], true
+}
+
+// HasPrefix reports whether p refers to q or a value below it.
+func (p Pointer) HasPrefix(q Pointer) bool {
+	if len(q) > len(p) {
+		return false
+	}
+	for i := range q {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval returns the value p refers to in doc, which must consist of the types
+// encoding/json decodes into an interface{}.
+func (p Pointer) Eval(doc interface{}) (interface{}, error) {
+	for i, token := range p {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, &Error{Pointer: p.String(), Token: i, Err: ErrKeyNotFound}
+			}
+			doc = v
+		case []interface{}:
+			j, err := Index(token, len(node))
+			if err != nil {
+				return nil, &Error{Pointer: p.String(), Token: i, Err: err}
+			}
+			doc = node[j]
+		default:
+			return nil, &Error{Pointer: p.String(), Token: i, Err: ErrNotContainer}
+		}
+	}
+	return doc, nil
+}
+
+// Index parses token as an index into an array of the given length. It
+// returns ErrEndOfArray for "-", ErrInvalidIndex for anything but a decimal
+// number without leading zeros and ErrIndexOutOfRange if the index is not
+// below length.
+func Index(token string, length int) (int, error) {
+	if token == "-" {
+		return 0, ErrEndOfArray
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrInvalidIndex
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidIndex
+		}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i >= length {
+		return 0, ErrIndexOutOfRange
+	}
+	return i, nil
+}
@@ -0,0 +1,120 @@
+package pointer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The example document from http://tools.ietf.org/html/rfc6901#section-5
+const rfcDoc = `{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8
+}`
+
+func TestEvalRFCExamples(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(rfcDoc), &doc))
+
+	cases := map[string]interface{}{
+		"/foo":   []interface{}{"bar", "baz"},
+		"/foo/0": "bar",
+		"/":      float64(0),
+		"/a~1b":  float64(1),
+		"/c%d":   float64(2),
+		"/e^f":   float64(3),
+		"/g|h":   float64(4),
+		"/i\\j":  float64(5),
+		"/k\"l":  float64(6),
+		"/ ":     float64(7),
+		"/m~0n":  float64(8),
+	}
+	for s, expected := range cases {
+		p, err := Parse(s)
+		assert.NoError(t, err)
+		v, err := p.Eval(doc)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, v, s)
+	}
+
+	p, err := Parse("")
+	assert.NoError(t, err)
+	v, err := p.Eval(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, doc, v)
+}
+
+func TestParseAndFormat(t *testing.T) {
+	p, err := Parse("/a~1b/~01/0")
+	assert.NoError(t, err)
+	assert.Equal(t, Pointer{"a/b", "~1", "0"}, p)
+	assert.Equal(t, "/a~1b/~01/0", p.String())
+	assert.Equal(t, "/a~1b/~01/0", Format("a/b", "~1", "0"))
+	assert.Equal(t, "/a~1b/~01/0/x", p.Append("x").String())
+
+	parent, last, ok := p.Parent()
+	assert.True(t, ok)
+	assert.Equal(t, "0", last)
+	assert.Equal(t, Pointer{"a/b", "~1"}, parent)
+	assert.True(t, p.HasPrefix(parent))
+	assert.False(t, parent.HasPrefix(p))
+
+	_, err = Parse("foo")
+	assert.True(t, errors.Is(err, ErrSyntax))
+
+	for _, s := range []string{"/a~", "/a~2", "/~01/~~0"} {
+		_, err = Parse(s)
+		assert.True(t, errors.Is(err, ErrInvalidEscape), s)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":[1, 2], "b":"c"}`), &doc))
+
+	cases := map[string]error{
+		"/x":     ErrKeyNotFound,
+		"/a/2":   ErrIndexOutOfRange,
+		"/a/-":   ErrEndOfArray,
+		"/a/01":  ErrInvalidIndex,
+		"/a/1e0": ErrInvalidIndex,
+		"/a/-1":  ErrInvalidIndex,
+		"/a/":    ErrInvalidIndex,
+		"/b/c":   ErrNotContainer,
+	}
+	for s, expected := range cases {
+		p, err := Parse(s)
+		assert.NoError(t, err)
+		_, err = p.Eval(doc)
+		assert.True(t, errors.Is(err, expected), "%s: %v", s, err)
+		var perr *Error
+		assert.True(t, errors.As(err, &perr))
+		assert.Equal(t, len(p)-1, perr.Token)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	i, err := Index("0", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, i)
+
+	i, err = Index("10", 11)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, i)
+
+	_, err = Index("1", 1)
+	assert.Equal(t, ErrIndexOutOfRange, err)
+
+	_, err = Index("99999999999999999999999", 1)
+	assert.Equal(t, ErrIndexOutOfRange, err)
+}
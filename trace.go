@@ -0,0 +1,76 @@
+package jsonpatch
+
+import "fmt"
+
+// Tracer receives the decisions the differ makes while creating a patch, see
+// WithTracer.
+type Tracer interface {
+	Trace(e TraceEvent)
+}
+
+// TracerFunc adapts an ordinary function to the Tracer interface.
+type TracerFunc func(e TraceEvent)
+
+// Trace calls f(e).
+func (f TracerFunc) Trace(e TraceEvent) {
+	f(e)
+}
+
+// Decisions reported in TraceEvent.Decision.
+const (
+	// TraceStrategy reports the algorithm chosen to compare an array.
+	TraceStrategy = "strategy"
+	// TraceKeep reports an array element left in place or a value its
+	// Equaler considers unchanged.
+	TraceKeep = "keep"
+	// TraceAdd reports a value that was added.
+	TraceAdd = "add"
+	// TraceRemove reports a value that was removed.
+	TraceRemove = "remove"
+	// TraceReplace reports a value replaced as a whole, e.g. because its type
+	// changed.
+	TraceReplace = "replace"
+	// TraceMove reports a value that was moved.
+	TraceMove = "move"
+	// TraceRecurse reports an object or array that is diffed member by member.
+	TraceRecurse = "recurse"
+	// TraceFullReplace reports an object or array whose changes were collapsed
+	// into a single replace because that is shorter.
+	TraceFullReplace = "full-replace"
+)
+
+// TraceEvent describes a single decision made while creating a patch.
+type TraceEvent struct {
+	// Differ is the part of the differ making the decision, "object",
+	// "array" or "value".
+	Differ string
+	// Decision is what was decided, one of the Trace constants.
+	Decision string
+	// Path is the JSON Pointer of the value the decision is about.
+	Path string
+	// Detail is a human readable explanation of the decision.
+	Detail string
+}
+
+// String formats e as a single line suitable for logging.
+func (e TraceEvent) String() string {
+	s := fmt.Sprintf("%s %s %q", e.Differ, e.Decision, e.Path)
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// trace reports a decision to the configured tracer. The detail is only
+// formatted when a tracer is set.
+func (d *differ) trace(differ, decision, path string, format string, args ...interface{}) {
+	if d.tracer == nil {
+		return
+	}
+	d.tracer.Trace(TraceEvent{
+		Differ:   differ,
+		Decision: decision,
+		Path:     path,
+		Detail:   fmt.Sprintf(format, args...),
+	})
+}
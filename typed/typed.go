@@ -0,0 +1,135 @@
+// Package typed creates and applies JSON Patches between Go values of the
+// same type, checked at compile time.
+//
+// Values are seen the way json.Marshal encodes them, see
+// jsonpatch.CreatePatchFromValues and jsonpatch.ApplyTo.
+package typed
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/herkyl/jsonpatch"
+)
+
+// Diff returns the patch turning a into b, configured by opts like
+// jsonpatch.CreatePatchWithOptions. Neither value is modified.
+func Diff[T any](a, b T, opts ...jsonpatch.Option) (jsonpatch.Patch, error) {
+	patch, err := jsonpatch.CreatePatchFromValues(a, b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.Patch(patch), nil
+}
+
+// Apply returns a copy of v with p applied to it. v itself, including the
+// values it points to, is never modified, even if applying p fails.
+func Apply[T any](v T, p jsonpatch.Patch) (T, error) {
+	c := deepCopy(v)
+	if err := jsonpatch.ApplyTo(&c, p); err != nil {
+		var zero T
+		return zero, err
+	}
+	return c, nil
+}
+
+// deepCopy returns a copy of v sharing no pointers, maps or slices with it,
+// except through unexported struct fields, which a patch cannot change.
+func deepCopy[T any](v T) T {
+	c := copyValue(reflect.ValueOf(&v).Elem(), map[visit]reflect.Value{})
+	return c.Interface().(T)
+}
+
+// visit identifies a pointer, map or slice being copied. A value referring
+// back to one of its parents refers to the parent's copy. Other values
+// referenced more than once are copied separately, as json.Marshal encodes
+// them separately and a patch may change them independently.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+func copyValue(v reflect.Value, seen map[visit]reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return c
+		}
+		key := visit{v.Type(), v.Pointer(), 0}
+		if p, ok := seen[key]; ok {
+			return p
+		}
+		p := reflect.New(v.Type().Elem())
+		seen[key] = p
+		p.Elem().Set(copyValue(v.Elem(), seen))
+		delete(seen, key)
+		c.Set(p)
+	case reflect.Interface:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(copyValue(v.Elem(), seen))
+	case reflect.Map:
+		if v.IsNil() {
+			return c
+		}
+		key := visit{v.Type(), v.Pointer(), 0}
+		if m, ok := seen[key]; ok {
+			return m
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		seen[key] = m
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), copyValue(iter.Value(), seen))
+		}
+		delete(seen, key)
+		c.Set(m)
+	case reflect.Slice:
+		if v.IsNil() {
+			return c
+		}
+		key := visit{v.Type(), v.Pointer(), v.Len()}
+		if s, ok := seen[key]; ok {
+			return s
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		seen[key] = s
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(copyValue(v.Index(i), seen))
+		}
+		delete(seen, key)
+		c.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i), seen))
+		}
+	case reflect.Struct:
+		c.Set(v)
+		copyFields(c, v, seen)
+	default:
+		c.Set(v)
+	}
+	return c
+}
+
+// copyFields replaces the exported fields of the struct c, a shallow copy of
+// v, by copies, including those promoted from embedded structs of unexported
+// types and from pointers to them.
+func copyFields(c, v reflect.Value, seen map[visit]reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath == "" {
+			c.Field(i).Set(copyValue(v.Field(i), seen))
+		} else if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			copyFields(c.Field(i), v.Field(i), seen)
+		} else if f.Anonymous && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+			// reflect does not set unexported fields, the pointer is
+			// replaced through its address
+			field := reflect.NewAt(f.Type, unsafe.Pointer(c.Field(i).UnsafeAddr())).Elem()
+			field.Set(copyValue(field, seen))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package typed
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/herkyl/jsonpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+type audit struct {
+	Version int               `json:"version"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type address struct {
+	Street string `json:"street"`
+}
+
+type resource struct {
+	audit
+	Name     string              `json:"name"`
+	Address  *address            `json:"address"`
+	Tags     []string            `json:"tags"`
+	Children map[string]*address `json:"children"`
+	Data     interface{}         `json:"data"`
+	private  *address
+}
+
+func newResource() resource {
+	shared := &address{Street: "shared"}
+	return resource{
+		audit:    audit{Version: 1, Labels: map[string]string{"a": "b"}},
+		Name:     "r",
+		Address:  shared,
+		Tags:     []string{"x", "y"},
+		Children: map[string]*address{"c": shared},
+		Data:     map[string]interface{}{"list": []interface{}{1.0}},
+		private:  shared,
+	}
+}
+
+func TestDiffAndApply(t *testing.T) {
+	a := newResource()
+	b := newResource()
+	b.Version = 2
+	b.Labels = map[string]string{"a": "c"}
+	b.Address = &address{Street: "other"}
+	b.Tags = []string{"y", "z"}
+	b.Children["d"] = &address{Street: "new"}
+	b.Data = map[string]interface{}{"list": []interface{}{1.0, 2.0}}
+
+	patch, err := Diff(a, b)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, patch)
+
+	result, err := Apply(a, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, b, result)
+	assert.Equal(t, newResource(), a, "the input is not modified")
+	assert.Same(t, a.private, result.private)
+
+	p, err := Apply(&a, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, &b, p)
+	assert.Equal(t, newResource(), a, "the input is not modified")
+}
+
+func TestApplyDoesNotModifyInputOnError(t *testing.T) {
+	a := newResource()
+	patch := jsonpatch.Patch{
+		jsonpatch.NewPatch("replace", "/address/street", "changed"),
+		jsonpatch.NewPatch("remove", "/tags/0", nil),
+		jsonpatch.NewPatch("replace", "/version", "not a number"),
+	}
+	result, err := Apply(a, patch)
+	var mismatch *jsonpatch.TypeMismatchError
+	assert.True(t, errors.As(err, &mismatch), "%v", err)
+	assert.Equal(t, resource{}, result)
+	assert.Equal(t, newResource(), a)
+}
+
+func TestDiffOptions(t *testing.T) {
+	a := map[string][]int{"list": {1, 2, 3}}
+	b := map[string][]int{"list": {4, 5, 6}}
+	patch, err := Diff(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(patch))
+
+	patch, err = Diff(a, b, jsonpatch.WithMinimisation(false))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(patch))
+
+	c, err := Apply(a, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, b, c)
+	assert.Equal(t, []int{1, 2, 3}, a["list"])
+}
+
+func TestCyclicValues(t *testing.T) {
+	type node struct {
+		Name string `json:"name"`
+		Next *node  `json:"-"`
+	}
+	n := &node{Name: "a"}
+	n.Next = n
+	c, err := Apply(n, jsonpatch.Patch{jsonpatch.NewPatch("replace", "/name", "b")})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", c.Name)
+	assert.Same(t, c, c.Next)
+	assert.Equal(t, "a", n.Name)
+}
+
+type inner struct {
+	Name string `json:"name"`
+}
+
+func TestApplyCopiesEmbeddedPointers(t *testing.T) {
+	type outer struct {
+		*inner
+		Count int `json:"count"`
+	}
+	o := outer{inner: &inner{Name: "a"}, Count: 1}
+	c, err := Apply(o, jsonpatch.Patch{jsonpatch.NewPatch("replace", "/name", "b")})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", c.Name)
+	assert.Equal(t, "a", o.inner.Name)
+	assert.NotSame(t, o.inner, c.inner)
+}
+
+func TestDiffAndApplyFixedArrays(t *testing.T) {
+	type route struct {
+		Stops  [4]string `json:"stops"`
+		Bounds [2]int    `json:"bounds"`
+	}
+	a := route{Stops: [4]string{"amsterdam central", "rotterdam central", "the hague central", "utrecht central"}, Bounds: [2]int{1, 2}}
+	for _, b := range []route{
+		{Stops: [4]string{a.Stops[0], a.Stops[1], a.Stops[2], "eindhoven central"}, Bounds: a.Bounds},
+		{Stops: [4]string{a.Stops[2], a.Stops[0], a.Stops[3], a.Stops[1]}, Bounds: [2]int{2, 1}},
+	} {
+		patch, err := Diff(a, b)
+		assert.NoError(t, err)
+		result, err := Apply(a, patch)
+		assert.NoError(t, err, "%v", patch)
+		assert.Equal(t, b, result, "%v", patch)
+	}
+}
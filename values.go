@@ -0,0 +1,178 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+)
+
+// valueKind is the JSON type of a Go value.
+type valueKind int
+
+const (
+	kindUnsupported valueKind = iota
+	kindNull
+	kindBool
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+)
+
+// kindOf returns the JSON type of v. Besides the types produced by
+// json.Unmarshal, json.Number and all Go integer and floating point types are
+// numbers.
+func kindOf(v interface{}) valueKind {
+	switch v.(type) {
+	case nil:
+		return kindNull
+	case bool:
+		return kindBool
+	case string:
+		return kindString
+	case []interface{}:
+		return kindArray
+	case map[string]interface{}:
+		return kindObject
+	case float64, float32, json.Number,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr:
+		return kindNumber
+	}
+	return kindUnsupported
+}
+
+// checkValue returns an *UnsupportedTypeError if v, found at path, is or
+// contains a value that has no JSON representation.
+func checkValue(v interface{}, path string) error {
+	switch vt := v.(type) {
+	case []interface{}:
+		for i, e := range vt {
+			if err := checkValue(e, makePath(path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for key, e := range vt {
+			if err := checkValue(e, makePath(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch kindOf(v) {
+	case kindUnsupported:
+		return &UnsupportedTypeError{Path: path, Value: v}
+	case kindNumber:
+		if _, ok := toNumber(v); !ok {
+			return &UnsupportedTypeError{Path: path, Value: v}
+		}
+	}
+	return nil
+}
+
+// unmarshalUseNumber is json.Unmarshal decoding numbers as json.Number, which
+// keeps their exact value and representation.
+func unmarshalUseNumber(data []byte, v interface{}) error {
+	if !json.Valid(data) {
+		// report the syntax error like json.Unmarshal does
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numberPrecision is the mantissa size in bits used to compare numbers that
+// are not both float64, enough for integers of any Go type and the usual
+// precision of decimals in json documents.
+const numberPrecision = 512
+
+// toNumber returns the numeric value of v, a value of kind kindNumber. It
+// returns false for json.Number values that are not valid numbers and for
+// infinite and NaN floats.
+func toNumber(v interface{}) (*big.Float, bool) {
+	f := new(big.Float).SetPrec(numberPrecision)
+	switch n := v.(type) {
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, false
+		}
+		return f.SetFloat64(n), true
+	case float32:
+		return toNumber(float64(n))
+	case json.Number:
+		if _, ok := f.SetString(string(n)); !ok || !isJSONNumber(string(n)) {
+			return nil, false
+		}
+		return f, true
+	case int:
+		return f.SetInt64(int64(n)), true
+	case int8:
+		return f.SetInt64(int64(n)), true
+	case int16:
+		return f.SetInt64(int64(n)), true
+	case int32:
+		return f.SetInt64(int64(n)), true
+	case int64:
+		return f.SetInt64(n), true
+	case uint:
+		return f.SetUint64(uint64(n)), true
+	case uint8:
+		return f.SetUint64(uint64(n)), true
+	case uint16:
+		return f.SetUint64(uint64(n)), true
+	case uint32:
+		return f.SetUint64(uint64(n)), true
+	case uint64:
+		return f.SetUint64(n), true
+	case uintptr:
+		return f.SetUint64(uint64(n)), true
+	}
+	return nil, false
+}
+
+// isJSONNumber reports whether s is a number literal as defined by RFC 8259,
+// big.Float accepts other notations too, like hexadecimal.
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && !isDigit(s[0])) || !isDigit(s[len(s)-1]) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// numbersEqual reports whether the numbers a and b have the same value, or
+// differ by no more than the float tolerance.
+func (o *options) numbersEqual(a, b interface{}) bool {
+	if o.numberMode == NumberLexical && o.floatTolerance == 0 {
+		as, aok := a.(json.Number)
+		bs, bok := b.(json.Number)
+		if aok && bok {
+			return as == bs
+		}
+	}
+	if af, ok := a.(float64); ok {
+		if bf, ok := b.(float64); ok {
+			return af == bf || (o.floatTolerance > 0 && math.Abs(af-bf) <= o.floatTolerance)
+		}
+	}
+	an, aok := toNumber(a)
+	bn, bok := toNumber(b)
+	if !aok || !bok {
+		return false
+	}
+	if an.Cmp(bn) == 0 {
+		return true
+	}
+	if o.floatTolerance == 0 {
+		return false
+	}
+	diff, _ := new(big.Float).Sub(an, bn).Float64()
+	return math.Abs(diff) <= o.floatTolerance
+}